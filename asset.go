@@ -1,53 +1,128 @@
 package oo
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 )
 
+// AssetType is the type of Ooyala asset
+type AssetType string
+
+// The types of Ooyala assets
+const (
+	AssetTypeVideo      AssetType = "video"
+	AssetTypeAudio      AssetType = "audio"
+	AssetTypeRemote     AssetType = "remote_asset"
+	AssetTypeLiveStream AssetType = "live_stream"
+	AssetTypeChannel    AssetType = "channel"
+	AssetTypeChannelSet AssetType = "channel_set"
+	AssetTypeYouTube    AssetType = "youtube"
+	AssetTypeAd         AssetType = "ad"
+)
+
 // Asset corresponds to Ooyala entity.
 type Asset struct {
-	Name             string           `json:"name"`
-	FileName         string           `json:"original_file_name"`
-	EmbedCode        string           `json:"embed_code"`
-	AssetType        string           `json:"asset_type"`
+	Name            string    `json:"name"`
+	FileName        string    `json:"original_file_name"`
+	EmbedCode       string    `json:"embed_code"`
+	AssetType       AssetType `json:"asset_type"`
+	Status          string    `json:"status"`
+	Description     string    `json:"description"`
+	ExternalID      string    `json:"external_id"`
+	PreviewImageURL string    `json:"preview_image_url"`
+	HostedAt        string    `json:"hosted_at"`
+	// Duration is the asset duration in milliseconds
+	Duration         int              `json:"duration"`
+	PlayerID         string           `json:"player_id"`
+	PublishingRuleID string           `json:"publishing_rule_id"`
+	IsLiveStream     bool             `json:"is_live_stream"`
+	PostProcessing   string           `json:"post_processing_status"`
 	TimeRestrictions TimeRestrictions `json:"time_restrictions"`
-	CreatedAt        string           `json:"created_at"`
-	UpdatedAt        string           `json:"updated_at"`
-	// file is used in upload processes
-	file *os.File
+	// Labels and Metadata are present if they are included in the request
+	Labels    []Label        `json:"labels"`
+	Metadata  CustomMetadata `json:"metadata"`
+	CreatedAt Time           `json:"created_at"`
+	UpdatedAt Time           `json:"updated_at"`
+	// Extra holds the fields of the response which are not described above
+	Extra map[string]json.RawMessage `json:"-"`
+	// source is the content read in upload processes
+	source *Source
 	// chunksize is needed in upload processes
 	chunksize int
+	// checksums are computed during the upload if enabled
+	checksums *Checksums
+}
+
+// assetFields are the json keys decoded into the fields of Asset
+var assetFields = jsonFields(reflect.TypeOf(Asset{}))
+
+// UnmarshalJSON decodes the asset keeping the unknown fields in Extra
+func (a *Asset) UnmarshalJSON(data []byte) error {
+	// asset has the same fields but not the methods, so there is no recursion
+	type asset Asset
+	if err := json.Unmarshal(data, (*asset)(a)); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, key := range assetFields {
+		delete(all, key)
+	}
+	a.Extra = nil
+	if len(all) > 0 {
+		a.Extra = all
+	}
+	return nil
 }
 
 // TimeRestrictions is used to set up asset availability by the time
 type TimeRestrictions struct {
 	Type      string `json:"type"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	StartDate Time   `json:"start_date"`
+	EndDate   Time   `json:"end_date"`
 }
 
 // CreateAsset sends a POST request creating a new asset in Ooyala account.
 // It assigns a videofile and chunksize to itself which is needed in upload actions.
 func (c *Client) CreateAsset(file *os.File, name string, chunksize int) (*Asset, error) {
+	return c.CreateAssetContext(context.Background(), file, name, chunksize)
+}
+
+// CreateAssetContext is the same as CreateAsset but with the given context
+func (c *Client) CreateAssetContext(ctx context.Context, file *os.File, name string, chunksize int) (*Asset, error) {
+	source, err := FileSource(file)
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateAssetSourceContext(ctx, source, name, chunksize)
+}
+
+// CreateAssetSource is the same as CreateAsset but the video is read from the given source
+func (c *Client) CreateAssetSource(source *Source, name string, chunksize int) (*Asset, error) {
+	return c.CreateAssetSourceContext(context.Background(), source, name, chunksize)
+}
+
+// CreateAssetSourceContext is the same as CreateAssetSource but with the given context
+func (c *Client) CreateAssetSourceContext(ctx context.Context, source *Source, name string, chunksize int) (*Asset, error) {
 	asset := &Asset{
-		file:      file,
+		source:    source,
 		Name:      name,
+		FileName:  source.Name,
 		chunksize: chunksize,
 	}
-	stat, err := file.Stat()
-	if err != nil {
-		return nil, err
-	}
-	asset.FileName = stat.Name()
 	if asset.Name == "" {
 		asset.Name = asset.FileName
 	}
-	filesize := strconv.FormatInt(stat.Size(), 10)
+	filesize := strconv.FormatInt(source.Size, 10)
 	// Prepare the request body
 	body := fmt.Sprintf(`{"name": "%v",
   "file_name": "%v",
@@ -56,7 +131,7 @@ func (c *Client) CreateAsset(file *os.File, name string, chunksize int) (*Asset,
 	"chunk_size": "%v"}`, asset.Name, asset.FileName, filesize, asset.chunksize)
 
 	// Create asset in Backlot
-	response, err := c.Post("/v2/assets", strings.NewReader(body))
+	response, err := c.PostContext(ctx, "/v2/assets", strings.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -76,20 +151,34 @@ func (c *Client) CreateAsset(file *os.File, name string, chunksize int) (*Asset,
 // ReplaceAsset sends a POST request preparing an asset for replacement in Ooyala account.
 // It assigns a videofile and chunksize to itself which is needed in upload actions.
 func (c *Client) ReplaceAsset(file *os.File, chunksize int, embedCode string) (*Asset, error) {
+	return c.ReplaceAssetContext(context.Background(), file, chunksize, embedCode)
+}
+
+// ReplaceAssetContext is the same as ReplaceAsset but with the given context
+func (c *Client) ReplaceAssetContext(ctx context.Context, file *os.File, chunksize int, embedCode string) (*Asset, error) {
+	source, err := FileSource(file)
+	if err != nil {
+		return &Asset{EmbedCode: embedCode, chunksize: chunksize}, err
+	}
+	return c.ReplaceAssetSourceContext(ctx, source, chunksize, embedCode)
+}
+
+// ReplaceAssetSource is the same as ReplaceAsset but the video is read from the given source
+func (c *Client) ReplaceAssetSource(source *Source, chunksize int, embedCode string) (*Asset, error) {
+	return c.ReplaceAssetSourceContext(context.Background(), source, chunksize, embedCode)
+}
+
+// ReplaceAssetSourceContext is the same as ReplaceAssetSource but with the given context
+func (c *Client) ReplaceAssetSourceContext(ctx context.Context, source *Source, chunksize int, embedCode string) (*Asset, error) {
 	asset := &Asset{
 		EmbedCode: embedCode,
-		file:      file,
+		source:    source,
 		chunksize: chunksize,
 	}
-
-	stat, err := file.Stat()
-	if err != nil {
-		return asset, err
-	}
-	filesize := strconv.FormatInt(stat.Size(), 10)
+	filesize := strconv.FormatInt(source.Size, 10)
 
 	body := fmt.Sprintf(`{"file_size": "%v", "chunk_size": "%v"}`, filesize, asset.chunksize)
-	response, err := c.Post("/v2/assets/"+asset.EmbedCode+"/replacement", strings.NewReader(body))
+	response, err := c.PostContext(ctx, "/v2/assets/"+asset.EmbedCode+"/replacement", strings.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -107,33 +196,54 @@ func (c *Client) ReplaceAsset(file *os.File, chunksize int, embedCode string) (*
 	return asset, nil
 }
 
-// GetAssets retreives an asset list from the Ooyala Account
+// GetAssets retreives an asset list from the Ooyala Account following all the pages
 func (c Client) GetAssets() ([]Asset, error) {
-	type data struct {
-		Assets []Asset `json:"items"`
-	}
-	var d data
+	return c.GetAssetsContext(context.Background())
+}
 
-	r, err := c.Get("/v2/assets")
-	if err != nil {
-		return nil, err
+// GetAssetsContext is the same as GetAssets but with the given context
+func (c Client) GetAssetsContext(ctx context.Context) ([]Asset, error) {
+	return c.FindAssetsContext(ctx, nil)
+}
+
+// FindAssets retreives the assets matching the query from the Ooyala Account.
+// Nil query returns all the assets
+func (c Client) FindAssets(q *Query) ([]Asset, error) {
+	return c.FindAssetsContext(context.Background(), q)
+}
+
+// FindAssetsContext is the same as FindAssets but with the given context
+func (c Client) FindAssetsContext(ctx context.Context, q *Query) ([]Asset, error) {
+	var assets []Asset
+	pager := c.PaginateQuery(ctx, "/v2/assets", q)
+	for pager.Next() {
+		var asset Asset
+		if err := pager.Decode(&asset); err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
 	}
-	decoder := json.NewDecoder(r.Body)
-	defer r.Body.Close()
-	// Read the request body
-	if err := decoder.Decode(&d); err != nil {
+	if err := pager.Err(); err != nil {
 		return nil, err
 	}
-	return d.Assets, nil
+	return assets, nil
 }
 
 // GetAsset retreives an asset by the embedcode from the Ooyala Account
 func (c Client) GetAsset(ec string) (*Asset, error) {
+	return c.GetAssetContext(context.Background(), ec)
+}
+
+// GetAssetContext is the same as GetAsset but with the given context
+func (c Client) GetAssetContext(ctx context.Context, ec string) (*Asset, error) {
 	var asset Asset
-	r, err := c.Get("/v2/assets/" + ec)
+	r, err := c.GetContext(ctx, "/v2/assets/"+ec)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
 	decoder := json.NewDecoder(r.Body)
 	defer r.Body.Close()
 	// Read the request body
@@ -142,3 +252,144 @@ func (c Client) GetAsset(ec string) (*Asset, error) {
 	}
 	return &asset, nil
 }
+
+// AssetPatch is a partial update of an asset. Only the fields which are not nil are sent
+type AssetPatch struct {
+	Name        *string
+	Description *string
+	// Duration is the asset duration in milliseconds
+	Duration         *int
+	TimeRestrictions *TimeRestrictions
+	// ClearTimeRestrictions removes the time restrictions of the asset
+	ClearTimeRestrictions bool
+	Status                *string
+	ExternalID            *string
+	HostedAt              *string
+}
+
+// MarshalJSON encodes only the fields set in the patch
+func (p AssetPatch) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	if p.Name != nil {
+		m["name"] = *p.Name
+	}
+	if p.Description != nil {
+		m["description"] = *p.Description
+	}
+	if p.Duration != nil {
+		m["duration"] = *p.Duration
+	}
+	if p.TimeRestrictions != nil {
+		m["time_restrictions"] = p.TimeRestrictions
+	}
+	if p.ClearTimeRestrictions {
+		m["time_restrictions"] = nil
+	}
+	if p.Status != nil {
+		m["status"] = *p.Status
+	}
+	if p.ExternalID != nil {
+		m["external_id"] = *p.ExternalID
+	}
+	if p.HostedAt != nil {
+		m["hosted_at"] = *p.HostedAt
+	}
+	return json.Marshal(m)
+}
+
+// StringPtr returns a pointer to the string, useful to fill AssetPatch
+func StringPtr(s string) *string {
+	return &s
+}
+
+// IntPtr returns a pointer to the int, useful to fill AssetPatch
+func IntPtr(i int) *int {
+	return &i
+}
+
+// patch returns the update of all the editable fields of the asset.
+// Duration is editable for remote assets only and status can be switched between live and paused.
+// The empty external id and hosted at are left out, so they aren't cleared
+// if the asset was fetched without them
+func (a Asset) patch() AssetPatch {
+	p := AssetPatch{
+		Name:        StringPtr(a.Name),
+		Description: StringPtr(a.Description),
+	}
+	if a.ExternalID != "" {
+		p.ExternalID = StringPtr(a.ExternalID)
+	}
+	if a.HostedAt != "" {
+		p.HostedAt = StringPtr(a.HostedAt)
+	}
+	if a.AssetType == AssetTypeRemote {
+		p.Duration = IntPtr(a.Duration)
+	}
+	if a.Status == "live" || a.Status == "paused" {
+		p.Status = StringPtr(a.Status)
+	}
+	if a.TimeRestrictions == (TimeRestrictions{}) {
+		p.ClearTimeRestrictions = true
+	} else {
+		tr := a.TimeRestrictions
+		p.TimeRestrictions = &tr
+	}
+	return p
+}
+
+// UpdateAsset writes all the editable fields of the given asset to the Ooyala Account
+// and returns the asset as it is stored after the update
+func (c Client) UpdateAsset(asset *Asset) (*Asset, error) {
+	return c.UpdateAssetContext(context.Background(), asset)
+}
+
+// UpdateAssetContext is the same as UpdateAsset but with the given context
+func (c Client) UpdateAssetContext(ctx context.Context, asset *Asset) (*Asset, error) {
+	return c.PatchAssetContext(ctx, asset.EmbedCode, asset.patch())
+}
+
+// PatchAsset updates only the fields set in the patch for the asset by the given embed code
+// and returns the asset as it is stored after the update
+func (c Client) PatchAsset(ec string, patch AssetPatch) (*Asset, error) {
+	return c.PatchAssetContext(context.Background(), ec, patch)
+}
+
+// PatchAssetContext is the same as PatchAsset but with the given context
+func (c Client) PatchAssetContext(ctx context.Context, ec string, patch AssetPatch) (*Asset, error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.PatchContext(ctx, "/v2/assets/"+ec, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	var asset Asset
+	if err := json.NewDecoder(r.Body).Decode(&asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// DeleteAsset deletes the asset by the given embed code from the Ooyala Account
+func (c Client) DeleteAsset(ec string) error {
+	return c.DeleteAssetContext(context.Background(), ec)
+}
+
+// DeleteAssetContext is the same as DeleteAsset but with the given context
+func (c Client) DeleteAssetContext(ctx context.Context, ec string) error {
+	r, err := c.DeleteContext(ctx, "/v2/assets/"+ec)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return checkServiceError(r, http.StatusOK)
+}
@@ -0,0 +1,35 @@
+package oo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAssetPatch(t *testing.T) {
+	tests := []struct {
+		asset Asset
+		want  string
+	}{
+		{
+			Asset{Name: "a", Status: "processing"},
+			`{"description":"","name":"a","time_restrictions":null}`,
+		},
+		{
+			Asset{Name: "a", ExternalID: "ext", HostedAt: "http://example.com", Status: "live"},
+			`{"description":"","external_id":"ext","hosted_at":"http://example.com","name":"a","status":"live","time_restrictions":null}`,
+		},
+		{
+			Asset{Name: "a", AssetType: AssetTypeRemote, Duration: 10},
+			`{"description":"","duration":10,"name":"a","time_restrictions":null}`,
+		},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.asset.patch())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("patch is %s, want %s", b, tt.want)
+		}
+	}
+}
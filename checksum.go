@@ -0,0 +1,215 @@
+package oo
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChecksumType selects the hash algorithms computed during the upload, the types can be combined with |
+type ChecksumType int
+
+const (
+	// MD5 computes MD5 of every chunk and of the whole file
+	MD5 ChecksumType = 1 << iota
+	// SHA256 computes SHA256 of every chunk and of the whole file
+	SHA256
+)
+
+// Checksums are the hex encoded hashes of the uploaded content
+type Checksums struct {
+	MD5    string
+	SHA256 string
+	// Chunks are the checksums of the chunks in the order of the uploading urls
+	Chunks []ChunkChecksum
+}
+
+// ChunkChecksum is the size and the hashes of a chunk
+type ChunkChecksum struct {
+	Index  int
+	Size   int64
+	MD5    string
+	SHA256 string
+}
+
+// SetChecksums enables the checksums of the given types. The whole file checksums
+// need all the content, so the chunks uploaded before the resume are read again
+func (u *Uploader) SetChecksums(types ChecksumType) {
+	u.checksums = types
+}
+
+// SetContentMD5 makes the chunks be sent with Content-MD5 header, so the storage rejects the corrupted ones.
+// MD5 checksums are computed as well
+func (u *Uploader) SetContentMD5(enabled bool) {
+	u.contentMD5 = enabled
+}
+
+// SetVerify enables the comparison of the uploaded file with the source file info
+// reported by Backlot after the processing is triggered. MD5 checksums are computed as well
+func (u *Uploader) SetVerify(verify bool) {
+	u.verify = verify
+}
+
+// ErrCannotVerify is returned by VerifyUpload if MD5 of the uploaded file can't be compared
+var ErrCannotVerify = errors.New("couldn't verify md5 of the uploaded file")
+
+// checksumTypes returns the checksums to compute including the ones needed by the other options
+func (u *Uploader) checksumTypes() ChecksumType {
+	types := u.checksums
+	if u.contentMD5 || u.verify {
+		types |= MD5
+	}
+	if u.dedupe.Policy != DedupeOff && u.dedupe.Match&MatchContentHash != 0 {
+		types |= SHA256
+	}
+	return types
+}
+
+// Checksums returns the checksums computed during the upload of the asset,
+// nil if they weren't enabled or the upload didn't succeed
+func (a *Asset) Checksums() *Checksums {
+	return a.checksums
+}
+
+// checksummer hashes the chunks in the order of the file.
+// It is used by the single goroutine reading the chunks
+type checksummer struct {
+	types  ChecksumType
+	md5    hash.Hash
+	sha256 hash.Hash
+	chunks []ChunkChecksum
+}
+
+func newChecksummer(types ChecksumType) *checksummer {
+	c := &checksummer{types: types}
+	if types&MD5 != 0 {
+		c.md5 = md5.New()
+	}
+	if types&SHA256 != 0 {
+		c.sha256 = sha256.New()
+	}
+	return c
+}
+
+func (c *checksummer) enabled() bool {
+	return c.types != 0
+}
+
+// add hashes the next chunk of the given size as it is read and returns its checksum
+func (c *checksummer) add(index int, chunk io.Reader, size int64) (ChunkChecksum, error) {
+	sum := ChunkChecksum{Index: index, Size: size}
+	var writers []io.Writer
+	var chunkMD5, chunkSHA256 hash.Hash
+	if c.md5 != nil {
+		chunkMD5 = md5.New()
+		writers = append(writers, c.md5, chunkMD5)
+	}
+	if c.sha256 != nil {
+		chunkSHA256 = sha256.New()
+		writers = append(writers, c.sha256, chunkSHA256)
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), chunk)
+	if err == nil && n != size {
+		err = fmt.Errorf("read %v of %v bytes", n, size)
+	}
+	if err != nil {
+		return sum, fmt.Errorf("couldn't hash chunk %v: %v", index, err)
+	}
+	if chunkMD5 != nil {
+		sum.MD5 = hex.EncodeToString(chunkMD5.Sum(nil))
+	}
+	if chunkSHA256 != nil {
+		sum.SHA256 = hex.EncodeToString(chunkSHA256.Sum(nil))
+	}
+	c.chunks = append(c.chunks, sum)
+	return sum, nil
+}
+
+func (c *checksummer) result() *Checksums {
+	if !c.enabled() {
+		return nil
+	}
+	sums := &Checksums{Chunks: c.chunks}
+	if c.md5 != nil {
+		sums.MD5 = hex.EncodeToString(c.md5.Sum(nil))
+	}
+	if c.sha256 != nil {
+		sums.SHA256 = hex.EncodeToString(c.sha256.Sum(nil))
+	}
+	return sums
+}
+
+// contentMD5 returns the value of Content-MD5 header for the hex encoded MD5
+func contentMD5(sum string) string {
+	b, _ := hex.DecodeString(sum)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// SourceFileInfo is the information about the uploaded file reported by Backlot
+type SourceFileInfo struct {
+	OriginalFileName string `json:"original_file_name"`
+	FileSize         int64  `json:"file_size"`
+	// MD5 is present if Backlot computed it for the file
+	MD5          string  `json:"md5"`
+	AudioCodec   string  `json:"audio_codec"`
+	VideoCodec   string  `json:"video_codec"`
+	FrameRate    float64 `json:"frame_rate"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	AudioBitrate int     `json:"audio_bitrate"`
+	VideoBitrate int     `json:"video_bitrate"`
+}
+
+// GetSourceFileInfo retreives the information about the file uploaded for the asset
+func (c Client) GetSourceFileInfo(ec string) (*SourceFileInfo, error) {
+	return c.GetSourceFileInfoContext(context.Background(), ec)
+}
+
+// GetSourceFileInfoContext is the same as GetSourceFileInfo but with the given context
+func (c Client) GetSourceFileInfoContext(ctx context.Context, ec string) (*SourceFileInfo, error) {
+	r, err := c.GetContext(ctx, "/v2/assets/"+ec+"/source_file_info")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var info SourceFileInfo
+	if err := json.NewDecoder(r.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// VerifyUpload compares the size and MD5 of the uploaded file reported by Backlot
+// with the source of the asset. The error wrapping ErrCannotVerify is returned
+// if Backlot doesn't report MD5 or it wasn't computed during the upload
+func (u *Uploader) VerifyUpload(ctx context.Context, asset *Asset) error {
+	info, err := u.client.GetSourceFileInfoContext(ctx, asset.EmbedCode)
+	if err != nil {
+		return err
+	}
+	if asset.source != nil && info.FileSize != asset.source.Size {
+		return fmt.Errorf("file size %v differs from uploaded %v", info.FileSize, asset.source.Size)
+	}
+	sums := asset.checksums
+	switch {
+	case info.MD5 == "":
+		return fmt.Errorf("%w: md5 isn't reported by Backlot", ErrCannotVerify)
+	case sums == nil || sums.MD5 == "":
+		return fmt.Errorf("%w: md5 wasn't computed during the upload", ErrCannotVerify)
+	case !strings.EqualFold(sums.MD5, info.MD5):
+		return fmt.Errorf("file md5 %v differs from uploaded %v", info.MD5, sums.MD5)
+	}
+	return nil
+}
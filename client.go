@@ -1,16 +1,16 @@
 package oo
 
 import (
-	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -38,10 +38,94 @@ type Client struct {
 	// For example https://api.ooyala.com for Backlot REST api
 	RootURL *url.URL
 	// Delta is the number of hours the request should stay valid
-	// Required further to generate expires value for a request
+	// Required further to generate expires value for a request unless WithExpiration or WithExpiresAt is used
 	Delta int
 	// out is used for logging requests
 	out io.Writer
+	// httpClient is used for sending requests to Ooyala APIs and uploading chunks
+	httpClient *http.Client
+	// limiter tracks the API credits and holds the requests when they run out
+	limiter *rateLimiter
+	// retry describes how the failed requests are repeated
+	retry RetryPolicy
+	// expiration and expiresAt override Delta if set
+	expiration time.Duration
+	expiresAt  time.Time
+	// clock corrects the expires values by the server time, nil if the correction is disabled
+	clock *clockSkew
+}
+
+// Option configures the Client created by NewClient
+type Option func(*clientConfig)
+
+// Middleware wraps a RoundTripper with additional behaviour like logging or metrics
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of ordinary functions as http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r)
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// clientConfig collects the options before the Client is built
+type clientConfig struct {
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	middlewares []Middleware
+	limiter     *rateLimiter
+	retry       *RetryPolicy
+	expiration  time.Duration
+	expiresAt   time.Time
+	skew        bool
+}
+
+// WithHTTPClient sets the http.Client used for all the requests.
+// Timeouts, proxies and TLS settings can be configured there.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = hc
+	}
+}
+
+// WithTransport sets the base RoundTripper of the http client
+func WithTransport(rt http.RoundTripper) Option {
+	return func(cfg *clientConfig) {
+		cfg.transport = rt
+	}
+}
+
+// WithMiddleware adds middlewares wrapping the transport of the http client.
+// The first middleware is the outermost one.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(cfg *clientConfig) {
+		cfg.middlewares = append(cfg.middlewares, mws...)
+	}
+}
+
+// build returns the http client assembled from the configured options
+func (cfg clientConfig) build() *http.Client {
+	if cfg.httpClient == nil && cfg.transport == nil && len(cfg.middlewares) == 0 {
+		return http.DefaultClient
+	}
+	hc := &http.Client{}
+	if cfg.httpClient != nil {
+		// copy the client so the one provided by the user is not modified
+		*hc = *cfg.httpClient
+	}
+	if cfg.transport != nil {
+		hc.Transport = cfg.transport
+	}
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(cfg.middlewares) - 1; i >= 0; i-- {
+		rt = cfg.middlewares[i](rt)
+	}
+	hc.Transport = rt
+	return hc
 }
 
 // ClientInterface is an interface which wraps up basic API calls
@@ -53,8 +137,20 @@ type ClientInterface interface {
 	Delete(path string) (*http.Response, error)
 }
 
+// ContextClientInterface is ClientInterface with the calls taking the context.
+// It is separate, so the existing implementations of ClientInterface still satisfy it
+type ContextClientInterface interface {
+	ClientInterface
+
+	GetContext(ctx context.Context, path string) (*http.Response, error)
+	PostContext(ctx context.Context, path string, body io.Reader) (*http.Response, error)
+	PutContext(ctx context.Context, path string, body io.Reader) (*http.Response, error)
+	PatchContext(ctx context.Context, path string, body io.Reader) (*http.Response, error)
+	DeleteContext(ctx context.Context, path string) (*http.Response, error)
+}
+
 // NewClient returns the pointer to the new instance of the Api object
-func NewClient(skey, akey, root string, delta int) (*Client, error) {
+func NewClient(skey, akey, root string, delta int, opts ...Option) (*Client, error) {
 	if strings.Contains(skey, ".") && !strings.Contains(akey, ".") {
 		return nil, errors.New("incorrect order of keys, first should be secrect key, then api key")
 	}
@@ -65,6 +161,25 @@ func NewClient(skey, akey, root string, delta int) (*Client, error) {
 	}
 	api.RootURL = u
 
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	api.httpClient = cfg.build()
+	api.limiter = cfg.limiter
+	if api.limiter == nil {
+		api.limiter = newRateLimiter(RateLimitThreshold, RateLimitPause)
+	}
+	api.retry = DefaultRetryPolicy()
+	if cfg.retry != nil {
+		api.retry = *cfg.retry
+	}
+	api.expiration = cfg.expiration
+	api.expiresAt = cfg.expiresAt
+	if cfg.skew {
+		api.clock = &clockSkew{}
+	}
+
 	api.out = ioutil.Discard
 	return api, nil
 }
@@ -74,9 +189,22 @@ func (c *Client) SetLogOut(out io.Writer) {
 	c.out = out
 }
 
+// HTTPClient returns the http client used by the Client
+func (c Client) HTTPClient() *http.Client {
+	if c.httpClient == nil {
+		return http.DefaultClient
+	}
+	return c.httpClient
+}
+
 // Get makes basic Get request to Ooayla APIs and returns http.Response
 func (c Client) Get(path string) (*http.Response, error) {
-	res, err := c.sendRequest(http.MethodGet, path, nil)
+	return c.GetContext(context.Background(), path)
+}
+
+// GetContext makes basic Get request to Ooayla APIs with the given context and returns http.Response
+func (c Client) GetContext(ctx context.Context, path string) (*http.Response, error) {
+	res, err := c.sendRequest(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +213,12 @@ func (c Client) Get(path string) (*http.Response, error) {
 
 // Post makes basic Post request to Ooayla APIs and returns http.Response
 func (c Client) Post(path string, body io.Reader) (*http.Response, error) {
-	res, err := c.sendRequest(http.MethodPost, path, body)
+	return c.PostContext(context.Background(), path, body)
+}
+
+// PostContext makes basic Post request to Ooayla APIs with the given context and returns http.Response
+func (c Client) PostContext(ctx context.Context, path string, body io.Reader) (*http.Response, error) {
+	res, err := c.sendRequest(ctx, http.MethodPost, path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +227,12 @@ func (c Client) Post(path string, body io.Reader) (*http.Response, error) {
 
 // Put makes basic Put request to Ooayla APIs and returns http.Response
 func (c Client) Put(path string, body io.Reader) (*http.Response, error) {
-	res, err := c.sendRequest(http.MethodPut, path, body)
+	return c.PutContext(context.Background(), path, body)
+}
+
+// PutContext makes basic Put request to Ooayla APIs with the given context and returns http.Response
+func (c Client) PutContext(ctx context.Context, path string, body io.Reader) (*http.Response, error) {
+	res, err := c.sendRequest(ctx, http.MethodPut, path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +241,12 @@ func (c Client) Put(path string, body io.Reader) (*http.Response, error) {
 
 // Patch makes basic Patch request to Ooayla APIs and returns http.Response
 func (c Client) Patch(path string, body io.Reader) (*http.Response, error) {
-	res, err := c.sendRequest(http.MethodPatch, path, body)
+	return c.PatchContext(context.Background(), path, body)
+}
+
+// PatchContext makes basic Patch request to Ooayla APIs with the given context and returns http.Response
+func (c Client) PatchContext(ctx context.Context, path string, body io.Reader) (*http.Response, error) {
+	res, err := c.sendRequest(ctx, http.MethodPatch, path, body)
 	if err != nil {
 		return nil, err
 	}
@@ -112,20 +255,40 @@ func (c Client) Patch(path string, body io.Reader) (*http.Response, error) {
 
 // Delete makes basic Delete request to Ooayla APIs and returns http.Response
 func (c Client) Delete(path string) (*http.Response, error) {
-	res, err := c.sendRequest(http.MethodDelete, path, nil)
+	return c.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext makes basic Delete request to Ooayla APIs with the given context and returns http.Response
+func (c Client) DeleteContext(ctx context.Context, path string) (*http.Response, error) {
+	res, err := c.sendRequest(ctx, http.MethodDelete, path, nil)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
-func (c Client) sendRequest(method, path string, body io.Reader) (*http.Response, error) {
-	req, err := c.NewRequest(method, path, body)
+func (c Client) sendRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	rewind, spooled, err := rewindable(body)
 	if err != nil {
 		return nil, err
 	}
-
-	res, err := http.DefaultClient.Do(req)
+	// the spooled body is removed when the transport closes the bodies of all the attempts
+	defer spooled.Close()
+	// every attempt is signed again, so the expires value is always fresh
+	res, err := c.do(ctx, true, func() (*http.Request, error) {
+		body, err := rewind()
+		if err != nil {
+			return nil, err
+		}
+		req, err := c.NewRequestWithContext(ctx, method, path, body)
+		if err != nil {
+			return nil, err
+		}
+		if req.Body != nil {
+			req.Body = readCloser{req.Body, spooled.acquire(req.Body)}
+		}
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -135,11 +298,41 @@ func (c Client) sendRequest(method, path string, body io.Reader) (*http.Response
 // NewRequest takes http method in lower or upper case, url query with parameters, and
 // body as any Reader and returns *http.Request ready for sending by the http client
 func (c Client) NewRequest(method, rawurl string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(strings.ToUpper(method), rawurl, body)
+	return c.NewRequestWithContext(context.Background(), method, rawurl, body)
+}
+
+// NewRequestWithContext is the same as NewRequest but the returned request carries the given context.
+// Reading the body for the signature is aborted once the context is done.
+// The body which can seek is read for the signature and rewound, so it isn't loaded into memory
+func (c Client) NewRequestWithContext(ctx context.Context, method, rawurl string, body io.Reader) (*http.Request, error) {
+	var getBody func() (io.ReadCloser, error)
+	if body != nil {
+		cr := &ctxReader{ctx: ctx, r: body}
+		if s, ok := body.(io.Seeker); ok {
+			if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+				getBody = func() (io.ReadCloser, error) {
+					if _, err := s.Seek(start, io.SeekStart); err != nil {
+						return nil, err
+					}
+					return ioutil.NopCloser(cr), nil
+				}
+			}
+		}
+		body = cr
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), rawurl, body)
 	if err != nil {
 		return nil, err
 	}
-	SignRequest(req, c)
+	if getBody != nil {
+		req.GetBody = getBody
+	}
+	if err := signRequest(req, c); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	req.URL = c.RootURL.ResolveReference(req.URL)
 	c.out.Write([]byte("REQUEST: " + req.Method + " " + req.URL.String() + "\n"))
@@ -147,10 +340,15 @@ func (c Client) NewRequest(method, rawurl string, body io.Reader) (*http.Request
 }
 
 // SignRequest gets a request, adds api_key, expires values,
-// generate and adds signature for the query in request.URL
+// generate and adds signature for the query in request.URL.
+// The body is hashed as it is read: it is taken from GetBody if the request has it or rewound
+// if it can seek, otherwise it is spooled to a temporary file removed when the body is closed.
+// The errors reading the body are ignored, Client.NewRequest returns them
 func SignRequest(r *http.Request, c Client) {
-	// perform initial string concantination
-	sig := c.Skey + strings.ToUpper(r.Method) + r.URL.Path
+	signRequest(r, c)
+}
+
+func signRequest(r *http.Request, c Client) error {
 	// get the query parameters and add api and expires there if they are absent
 	q := r.URL.Query()
 	if _, ok := q["api_key"]; !ok {
@@ -159,43 +357,107 @@ func SignRequest(r *http.Request, c Client) {
 	if _, ok := q["expires"]; !ok {
 		q.Set("expires", c.expires())
 	}
-	// sort url parameters by keys alphabetically and contaninate them like a=1b=2c=3
-	sig += fmtKeys(q)
-	// convert body to string
-	if r.Body != nil {
-		b, _ := ioutil.ReadAll(r.Body)
-		r.ContentLength = int64(len(b))
-		sig += string(b)
-		// return reader with content back to Body
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
-	}
-	// Generate a SHA-256 digest in base64 and truncate the string to 43 characters
-	sum := sha256.Sum256([]byte(sig))
-	sig = string(base64.StdEncoding.EncodeToString(sum[:]))[:43]
+	canonical := CanonicalString(r.Method, r.URL.Path, q, nil)
+	signature, err := signBody(r, c.Skey, canonical)
 	// Adding signature to the query parameters and encode them
-	q.Set("signature", sig)
+	q.Set("signature", signature)
 	// Reassing query with all parameters to the url again
 	r.URL.RawQuery = q.Encode()
+	if err != nil {
+		return fmt.Errorf("couldn't read body: %w", err)
+	}
+	return nil
+}
+
+// signBody computes the signature of the canonical string followed by the body of the request
+// and leaves the body ready to be sent from the beginning
+func signBody(r *http.Request, secret, canonical string) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, secret+canonical)
+	sign := func() string { return base64.StdEncoding.EncodeToString(h.Sum(nil))[:43] }
+	if r.Body == nil || r.Body == http.NoBody {
+		return sign(), nil
+	}
+
+	if r.GetBody == nil {
+		if s, ok := r.Body.(io.Seeker); ok {
+			if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+				body := r.Body
+				r.GetBody = func() (io.ReadCloser, error) {
+					if _, err := s.Seek(start, io.SeekStart); err != nil {
+						return nil, err
+					}
+					return ioutil.NopCloser(body), nil
+				}
+			}
+		}
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return sign(), err
+		}
+		n, err := io.Copy(h, body)
+		body.Close()
+		if err != nil {
+			return sign(), err
+		}
+		// the body given by GetBody may share the offset with r.Body, so it is taken once more
+		if body, err = r.GetBody(); err != nil {
+			return sign(), err
+		}
+		r.Body = readCloser{body, r.Body}
+		r.ContentLength = n
+		return sign(), nil
+	}
+
+	// the body can be read only once, it is spooled while hashed
+	spooled, closer, err := spool(io.TeeReader(r.Body, h))
+	r.Body.Close()
+	if err != nil {
+		r.Body = http.NoBody
+		return sign(), err
+	}
+	n, err := spooled.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = spooled.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closer.Close()
+		return sign(), err
+	}
+	r.Body = readCloser{spooled, closer}
+	r.ContentLength = n
+	return sign(), nil
+}
+
+// CanonicalString returns the string the signature is computed from without the secret key:
+// the method, the path, the parameters sorted by keys like a=1b=2c=3 and the body
+func CanonicalString(method, path string, q url.Values, body []byte) string {
+	return strings.ToUpper(method) + path + fmtKeys(q) + string(body)
 }
 
-// Expires genrates expires value based on Delta value
-func (c Client) expires() string {
-	// Generate the expires value by adding c.Delta to the current time
-	timestamp := time.Now().Add(time.Hour * time.Duration(c.Delta)).Unix()
-	expires := strconv.FormatInt(timestamp, 10)
-	return expires
+// Signature generates a SHA-256 digest of the secret key and the canonical string
+// in base64 truncated to 43 characters
+func Signature(secret, canonical string) string {
+	sum := sha256.Sum256([]byte(secret + canonical))
+	return base64.StdEncoding.EncodeToString(sum[:])[:43]
 }
 
-// fmtKeys sort url parameters by keys alphabetically and contaninate them like a=1b=2c=3
+// fmtKeys sort url parameters by keys alphabetically and contaninate them like a=1b=2c=3.
+// The values are decoded. A repeated key is written once per value
+// in the order the values are given, like a=1a=2b=3, and an empty value as a=
 func fmtKeys(q url.Values) string {
-	var result string
+	var result strings.Builder
 	var keys []string
 	for k := range q {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		result += k + "=" + q[k][0] // might be issue with [0]
+		for _, v := range q[k] {
+			result.WriteString(k + "=" + v)
+		}
 	}
-	return result
+	return result.String()
 }
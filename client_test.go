@@ -0,0 +1,277 @@
+package oo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The golden signatures are computed independently from the canonical strings:
+// base64(sha256(secret + canonical)) truncated to 43 characters
+const (
+	goldenSecret = "329b5b204d0f11e0a2d060334bfffe90ab18xqh5"
+	goldenAPIKey = "7ab06"
+)
+
+var goldenVectors = []struct {
+	name      string
+	method    string
+	url       string
+	body      string
+	canonical string
+	signature string
+}{
+	{
+		name:      "plain",
+		method:    "GET",
+		url:       "/v2/assets?api_key=7ab06&expires=1893456000",
+		canonical: "GET/v2/assetsapi_key=7ab06expires=1893456000",
+		signature: "fOlRlljEZYPCFY1eKUkZXUx6ktboKG7cwZWwQ1p+uKQ",
+	},
+	{
+		name:      "repeated keys keep the order of values",
+		method:    "GET",
+		url:       "/v2/assets?b=2&api_key=7ab06&a=1&expires=1893456000&b=1",
+		canonical: "GET/v2/assetsa=1api_key=7ab06b=2b=1expires=1893456000",
+		signature: "vvDQfho7tpp7Wlc/yVXEf3Zx2ZxOEZFv8xRUb7bEd7E",
+	},
+	{
+		name:      "spaces encoded as %20 and +",
+		method:    "GET",
+		url:       "/v2/assets?api_key=7ab06&expires=1893456000&q=a%20b&r=c+d",
+		canonical: "GET/v2/assetsapi_key=7ab06expires=1893456000q=a br=c d",
+		signature: "QwEsDTz0wZYp8arpysYil305jA/C0zTSrD8rlEVCE4I",
+	},
+	{
+		name:      "encoded plus and quotes",
+		method:    "GET",
+		url:       "/v2/assets?api_key=7ab06&expires=1893456000&where=name%3D%27a%2Bb%27",
+		canonical: "GET/v2/assetsapi_key=7ab06expires=1893456000where=name='a+b'",
+		signature: "v0KLZKXegZRP6DVJvLIZ8C2zv+6Hg81JQrAyhZLXudk",
+	},
+	{
+		name:      "unicode",
+		method:    "GET",
+		url:       "/v2/assets?api_key=7ab06&expires=1893456000&name=%D0%BF%D1%80%D0%B8+%D0%B2%D0%B5%D1%82",
+		canonical: "GET/v2/assetsapi_key=7ab06expires=1893456000name=при вет",
+		signature: "eIoR8YEgaNgSwT/huBwAKCTj3SKwAZCflcIKOv4S7oE",
+	},
+	{
+		name:      "empty values",
+		method:    "GET",
+		url:       "/v2/assets?api_key=7ab06&e=&expires=1893456000&f",
+		canonical: "GET/v2/assetsapi_key=7ab06e=expires=1893456000f=",
+		signature: "4bb7rbgZyqvlwC2pPNglSINQ9Eqy1ygkFgiM8gk7gpQ",
+	},
+	{
+		name:      "body",
+		method:    "POST",
+		url:       "/v2/assets?api_key=7ab06&expires=1893456000",
+		body:      `{"name": "x"}`,
+		canonical: `POST/v2/assetsapi_key=7ab06expires=1893456000{"name": "x"}`,
+		signature: "DaQMzM8luOKe1B7njiNt6T9KI9vPlV6ORfv9AtHnnFA",
+	},
+	{
+		name:      "keys sorted by bytes",
+		method:    "GET",
+		url:       "/v2/assets?ab=3&a_b=2&api_key=7ab06&B=1&expires=1893456000",
+		canonical: "GET/v2/assetsB=1a_b=2ab=3api_key=7ab06expires=1893456000",
+		signature: "I+9vnKUduKGWcUFydxCWUgXUwBFkfUy5HDSeRyfhPxg",
+	},
+	{
+		name:      "encoded path and lowercase method",
+		method:    "patch",
+		url:       "/v2/assets/abc/metadata/my%20key?api_key=7ab06&expires=1893456000",
+		canonical: "PATCH/v2/assets/abc/metadata/my keyapi_key=7ab06expires=1893456000",
+		signature: "IhjZ98hnjLFzMIchu5ldsYpaalCrqnDw060UJlWJ4VQ",
+	},
+}
+
+func TestCanonicalString(t *testing.T) {
+	for _, v := range goldenVectors {
+		r := newGoldenRequest(t, v.method, v.url, v.body)
+		got := CanonicalString(r.Method, r.URL.Path, r.URL.Query(), []byte(v.body))
+		if got != v.canonical {
+			t.Errorf("%v: canonical string is %q, want %q", v.name, got, v.canonical)
+		}
+		if sig := Signature(goldenSecret, got); sig != v.signature {
+			t.Errorf("%v: signature is %v, want %v", v.name, sig, v.signature)
+		}
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	c := Client{Skey: goldenSecret, Akey: goldenAPIKey, Delta: 1}
+	for _, v := range goldenVectors {
+		r := newGoldenRequest(t, v.method, v.url, v.body)
+		SignRequest(r, c)
+		if sig := r.URL.Query().Get("signature"); sig != v.signature {
+			t.Errorf("%v: signature is %v, want %v", v.name, sig, v.signature)
+		}
+		// the encoded query must be signed the same way by the receiver
+		check, err := VerifySignature(r, goldenSecret)
+		if err != nil {
+			t.Fatalf("%v: %v", v.name, err)
+		}
+		if check.Canonical != v.canonical || check.Expected != v.signature {
+			t.Errorf("%v: verified %q with %v, want %q with %v", v.name, check.Canonical, check.Expected, v.canonical, v.signature)
+		}
+	}
+}
+
+func TestSignRequestAddsKeys(t *testing.T) {
+	c := Client{Skey: goldenSecret, Akey: goldenAPIKey, Delta: 1}
+	r := newGoldenRequest(t, "GET", "/v2/assets?where=a%3D1&where=b%3D2", "")
+	SignRequest(r, c)
+	q := r.URL.Query()
+	if q.Get("api_key") != goldenAPIKey || q.Get("expires") == "" {
+		t.Fatalf("api_key or expires is absent in %v", r.URL.RawQuery)
+	}
+	if got := q["where"]; len(got) != 2 || got[0] != "a=1" || got[1] != "b=2" {
+		t.Errorf("repeated values are %v, want [a=1 b=2]", got)
+	}
+	check, err := VerifySignature(r, goldenSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Valid {
+		t.Errorf("signature of %q is invalid", check.Canonical)
+	}
+}
+
+func TestExpires(t *testing.T) {
+	at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, err := NewClient(goldenSecret, goldenAPIKey, BacklotDefaultEndpoint, 15, WithExpiresAt(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.expires(); got != strconv.FormatInt(at.Unix(), 10) {
+		t.Errorf("expires is %v, want %v", got, at.Unix())
+	}
+
+	c, err = NewClient(goldenSecret, goldenAPIKey, BacklotDefaultEndpoint, 15, WithExpiration(30*time.Second), WithSkewCorrection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExpires := func(want time.Time) {
+		t.Helper()
+		got, err := strconv.ParseInt(c.expires(), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Unix(got, 0).Sub(want); d < -2*time.Second || d > 2*time.Second {
+			t.Errorf("expires is %v, want about %v", time.Unix(got, 0), want)
+		}
+	}
+	checkExpires(time.Now().Add(30 * time.Second))
+
+	// the server clock is an hour ahead
+	h := http.Header{}
+	now := time.Now()
+	h.Set("Date", now.Add(time.Hour).UTC().Format(http.TimeFormat))
+	c.clock.update(h, now, now, ioutil.Discard)
+	if skew := c.ClockSkew(); skew < time.Hour-time.Second || skew > time.Hour+time.Second {
+		t.Errorf("clock skew is %v, want about 1h", skew)
+	}
+	checkExpires(time.Now().Add(time.Hour + 30*time.Second))
+}
+
+func TestSkewFromAPIOnly(t *testing.T) {
+	// every host reports the clock an hour ahead
+	c, err := NewClient(goldenSecret, goldenAPIKey, BacklotDefaultEndpoint, 15, WithSkewCorrection(), WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			h := http.Header{}
+			h.Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+			return &http.Response{StatusCode: http.StatusOK, Header: h, Body: http.NoBody}, nil
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(rawurl string) {
+		t.Helper()
+		_, err := c.do(context.Background(), false, func() (*http.Request, error) {
+			return http.NewRequest("PUT", rawurl, nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("https://uploads.example.com/chunk/0")
+	if skew := c.ClockSkew(); skew != 0 {
+		t.Errorf("clock skew is %v after the upload host response, want 0", skew)
+	}
+	send(c.RootURL.String() + "/v2/assets")
+	if skew := c.ClockSkew(); skew < time.Hour-2*time.Second || skew > time.Hour+2*time.Second {
+		t.Errorf("clock skew is %v after the API response, want about 1h", skew)
+	}
+}
+
+func TestCancelledBody(t *testing.T) {
+	c, err := NewClient(goldenSecret, goldenAPIKey, BacklotDefaultEndpoint, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.PostContext(ctx, "/v2/assets", strings.NewReader("{}")); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+// onlyReader hides the other methods of the reader like Seek
+type onlyReader struct {
+	io.Reader
+}
+
+func TestSpooledBodyOutlivesResponse(t *testing.T) {
+	// the transport responds before it sends the body
+	var sent *http.Request
+	c, err := NewClient(goldenSecret, goldenAPIKey, BacklotDefaultEndpoint, 15, WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			sent = r
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+		})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := bytes.Repeat([]byte("0123456789"), spoolLimit/10+10)
+	before, _ := filepath.Glob(filepath.Join(os.TempDir(), "oo-body-*"))
+
+	res, err := c.PostContext(context.Background(), "/v2/assets", onlyReader{bytes.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	got, err := ioutil.ReadAll(sent.Body)
+	if err != nil {
+		t.Fatalf("the body can't be read after the response: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("body of %v bytes is sent, want %v", len(got), len(content))
+	}
+	sent.Body.Close()
+	if after, _ := filepath.Glob(filepath.Join(os.TempDir(), "oo-body-*")); len(after) > len(before) {
+		t.Errorf("the spooled body is left after it is closed: %v", after)
+	}
+}
+
+func newGoldenRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+	r, err := http.NewRequest(method, "https://api.ooyala.com"+url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
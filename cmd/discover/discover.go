@@ -29,7 +29,7 @@ func (p pairs) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 func (p pairs) Less(i, j int) bool {
-	return p[i].target.UpdatedAt > p[j].target.UpdatedAt
+	return p[i].target.UpdatedAt.After(p[j].target.UpdatedAt.Time)
 }
 
 func (p pairs) renderAggregateResult(writer io.Writer) error {
@@ -80,11 +80,11 @@ func buildTableHeader(first string, numRows int) []string {
 func formTableData(p pairs) [][]string {
 	var tableData [][]string
 	for _, pr := range p {
-		tableRow := []string{pr.target.EmbedCode + "\n" + pr.target.UpdatedAt}
+		tableRow := []string{pr.target.EmbedCode + "\n" + pr.target.UpdatedAt.Format(oo.TimeFormat)}
 
 		var results []string
 		for _, similar := range pr.similars.Assets {
-			results = append(results, similar.EmbedCode+"\n"+similar.Reason+"\n"+similar.CreatedAt)
+			results = append(results, similar.EmbedCode+"\n"+similar.Reason+"\n"+similar.CreatedAt.Format(oo.TimeFormat))
 		}
 		tableRow = append(tableRow, results...)
 
@@ -111,8 +111,12 @@ func loadDataFromCSV(path string) ([]*oo.Asset, error) {
 		if i == 0 {
 			continue
 		}
+		updatedAt, err := oo.ParseTime(line[0])
+		if err != nil {
+			return nil, err
+		}
 		target := &oo.Asset{
-			UpdatedAt: line[0],
+			UpdatedAt: updatedAt,
 			EmbedCode: line[1],
 			Name:      line[2],
 		}
@@ -68,8 +68,7 @@ func main() {
 		v.Add("limit", strconv.Itoa(limit))
 	}
 	if updatedAt != "" {
-		val := fmt.Sprintf("updated_at<'%v'", updatedAt)
-		v.Add("where", val)
+		v.Add("where", oo.Field("updated_at").Lt(updatedAt).String())
 	}
 
 	targets, err := loadDataFromCSV(inFile)
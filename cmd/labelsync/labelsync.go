@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dimdiden/oo"
+)
+
+// The input file is JSON with either the list of full label names:
+// ["/Sports/Football", "/Sports/Tennis"]
+// or the nested tree of the names:
+// {"Sports": {"Football": {}, "Tennis": {}}}
+// YAML isn't supported, such files should be converted to JSON first.
+// Running the tool again with the same file changes nothing.
+
+func main() {
+	// Flag block
+	secret := flag.String("s", "", "specify secret key")
+	api := flag.String("a", "", "specify api key")
+	path := flag.String("f", "", "specify path to JSON file with the labels hierarchy, YAML isn't supported")
+	prune := flag.Bool("prune", false, "delete the labels absent in the file")
+	dry := flag.Bool("n", false, "dry run, only print the changes")
+	verbose := flag.Bool("v", false, "verbose mode")
+	flag.Parse()
+
+	if *secret == "" || *api == "" || *path == "" {
+		fmt.Println("Incorrect usage, please specify the required parameters")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	desired, err := loadPaths(*path)
+	if err != nil {
+		log.Fatal("could not read labels: ", err)
+	}
+
+	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15)
+	if *verbose {
+		ooClient.SetLogOut(os.Stdout)
+	}
+
+	labels, err := ooClient.GetLabels()
+	if err != nil {
+		log.Fatal(err)
+	}
+	existing := map[string]oo.Label{}
+	for _, l := range labels {
+		existing[oo.CleanLabelPath(l.FullName)] = l
+	}
+
+	var missing []string
+	for _, p := range desired {
+		if _, ok := existing[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	for _, p := range missing {
+		fmt.Println("create: ", p)
+	}
+	if !*dry && len(missing) > 0 {
+		if _, err := ooClient.CreateLabelPaths(missing...); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *prune {
+		keep := map[string]bool{}
+		for _, p := range desired {
+			// the parents of the desired labels stay as well
+			for ; p != "/" && p != ""; p = p[:strings.LastIndex(p, "/")] {
+				keep[p] = true
+			}
+		}
+		var extra []string
+		for p := range existing {
+			if !keep[p] {
+				extra = append(extra, p)
+			}
+		}
+		// delete the children before their parents
+		sort.Slice(extra, func(i, j int) bool { return len(extra[i]) > len(extra[j]) })
+		for _, p := range extra {
+			fmt.Println("delete: ", p)
+			if *dry {
+				continue
+			}
+			if err := ooClient.DeleteLabel(existing[p].ID); err != nil && !oo.IsNotFound(err) {
+				log.Fatal(err)
+			}
+		}
+	}
+	fmt.Println("labels are in sync")
+}
+
+// loadPaths reads the file and returns the sorted full names of the labels
+func loadPaths(path string) ([]string, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		return nil, fmt.Errorf("YAML isn't supported, convert %v to JSON", path)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("label should be a string: %v", item)
+			}
+			paths = append(paths, oo.CleanLabelPath(s))
+		}
+	case map[string]interface{}:
+		paths = flatten("", v)
+	default:
+		return nil, fmt.Errorf("expected list or object of labels")
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// flatten converts the nested object into the list of full names
+func flatten(prefix string, tree map[string]interface{}) []string {
+	var paths []string
+	for name, children := range tree {
+		p := oo.CleanLabelPath(prefix + "/" + name)
+		paths = append(paths, p)
+		if sub, ok := children.(map[string]interface{}); ok {
+			paths = append(paths, flatten(p, sub)...)
+		}
+	}
+	return paths
+}
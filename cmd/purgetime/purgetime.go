@@ -4,19 +4,13 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/dimdiden/oo"
 )
 
-// var timer = time.NewTimer(2 * time.Second)
-
 func main() {
 	// Flag block
 	secret := flag.String("s", "", "specify secret key")
@@ -37,7 +31,7 @@ func main() {
 	}
 	defer file.Close()
 
-	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15)
+	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15, oo.WithRateLimit(oo.RateLimitThreshold, 2*time.Minute))
 	if *verbose {
 		ooClient.SetLogOut(os.Stdout)
 	}
@@ -60,31 +54,11 @@ func main() {
 	}
 }
 
-func purgeTime(oo oo.Apier, embedCode string) error {
-	response, err := oo.Patch("/v2/assets/"+embedCode, strings.NewReader(`{"time_restrictions": null}`))
-	if err != nil {
+func purgeTime(client *oo.Client, embedCode string) error {
+	if _, err := client.PatchAsset(embedCode, oo.AssetPatch{ClearTimeRestrictions: true}); err != nil {
 		return err
 	}
-
-	credits, err := strconv.Atoi(response.Header.Get("X-RateLimit-Credits"))
-	if err != nil {
-		return err
-	}
-
-	fmt.Println("credits left: ", response.Header.Get("X-RateLimit-Credits"))
-	result, err := ioutil.ReadAll(response.Body)
-	defer response.Body.Close()
-	if err != nil {
-		return err
-	}
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("request failed: [%v] [%v]", response.StatusCode, string(result))
-	}
-
-	timer := time.NewTimer(2 * time.Minute)
-	if credits < 100 {
-		fmt.Println("Only 100 credits left. Waiting for 2 min...")
-		<-timer.C
-	}
+	// the client holds the requests by itself when the credits run out
+	fmt.Println("credits left: ", client.RateLimit().Credits)
 	return nil
 }
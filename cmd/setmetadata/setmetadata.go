@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dimdiden/oo"
+)
+
+// The input file is CSV with the header like:
+// embed_code,key1,key2
+// The column embed_code is required, the others are metadata keys.
+// Empty cells are skipped unless -d is set, then the keys are deleted.
+
+func main() {
+	// Flag block
+	secret := flag.String("s", "", "specify secret key")
+	api := flag.String("a", "", "specify api key")
+	path := flag.String("f", "", "specify path to CSV file with embed_code column and metadata keys in the header")
+	replace := flag.Bool("r", false, "replace all the metadata of the asset instead of merging")
+	remove := flag.Bool("d", false, "delete the keys with empty values")
+	verbose := flag.Bool("v", false, "verbose mode")
+	flag.Parse()
+
+	if *secret == "" || *api == "" || *path == "" {
+		fmt.Println("Incorrect usage, please specify the required parameters")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatal("could not open file: ", err)
+	}
+	defer file.Close()
+
+	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15, oo.WithRateLimit(oo.RateLimitThreshold, 2*time.Minute))
+	if *verbose {
+		ooClient.SetLogOut(os.Stdout)
+	}
+
+	r := csv.NewReader(file)
+	lines, err := r.ReadAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(lines) == 0 {
+		log.Fatal("the file is empty")
+	}
+
+	header := lines[0]
+	ecColumn := -1
+	for i, key := range header {
+		if key == "embed_code" {
+			ecColumn = i
+		}
+	}
+	if ecColumn < 0 {
+		log.Fatal("embed_code column is absent in the header")
+	}
+
+	for _, line := range lines[1:] {
+		embedCode := line[ecColumn]
+		if err := setMetadata(ooClient, embedCode, header, line, *replace, *remove); err != nil {
+			log.Fatalf("could not process asset %v: %v", embedCode, err)
+		}
+		fmt.Printf("asset %v has been processed\n", embedCode)
+	}
+}
+
+func setMetadata(client *oo.Client, embedCode string, header, line []string, replace, remove bool) error {
+	m := oo.CustomMetadata{}
+	var empty []string
+	for i, key := range header {
+		if key == "embed_code" || i >= len(line) {
+			continue
+		}
+		if line[i] == "" {
+			empty = append(empty, key)
+			continue
+		}
+		m[key] = line[i]
+	}
+
+	if replace {
+		if _, err := client.SetMetadata(embedCode, m); err != nil {
+			return err
+		}
+		// the empty keys are gone already after replacement
+		return nil
+	}
+	if len(m) > 0 {
+		if _, err := client.PatchMetadata(embedCode, m); err != nil {
+			return err
+		}
+	}
+	if remove {
+		for _, key := range empty {
+			if err := client.DeleteMetadataKey(embedCode, key); err != nil && !oo.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
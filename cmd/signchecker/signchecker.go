@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/dimdiden/oo"
 )
@@ -23,13 +24,30 @@ func main() {
 	query := flag.String("q", "", "specify url query needed to be signed")
 	method := flag.String("m", "", "specify the http method for the request")
 	body := flag.String("b", "", "specify either JSON or the path to the binary file")
+	expiration := flag.Duration("e", 15*time.Hour, "specify how long the signed query stays valid, like 90s or 2h")
+	verify := flag.Bool("verify", false, "verify the signature of the signed url query instead of signing it, api key is not needed")
 	flag.Parse()
 
-	if *skey == "" || *akey == "" || *query == "" || *method == "" {
+	if *skey == "" || (*akey == "" && !*verify) || *query == "" || *method == "" {
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	if *verify {
+		b, err := checkBody(*body)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := http.NewRequest(strings.ToUpper(*method), *query, b)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !verifyRequest(r, *skey, *akey) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse and check the provided query
 	u, err := url.Parse(*query)
 	if err != nil {
@@ -43,7 +61,7 @@ func main() {
 		log.Fatal("signature is already present in the provided query")
 	}
 	// Create the new ooyala client
-	ooClient, err := oo.NewClient(*skey, *akey, "", 15)
+	ooClient, err := oo.NewClient(*skey, *akey, "", 15, oo.WithExpiration(*expiration))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -60,9 +78,6 @@ func main() {
 	}
 	// Signing the query
 	oo.SignRequest(r, *ooClient)
-	if err != nil {
-		log.Fatal(err)
-	}
 	fmt.Println("=========================")
 	fmt.Println("SIGNED REQUEST: ", r.URL.String())
 }
@@ -92,3 +107,103 @@ func isJSON(str string) bool {
 	var js json.RawMessage
 	return json.Unmarshal([]byte(str), &js) == nil
 }
+
+// verifyRequest prints the result of the signature verification and the likely reasons of the mismatch
+func verifyRequest(r *http.Request, skey, akey string) bool {
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	check, err := oo.VerifySignature(r, skey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("=========================")
+	fmt.Println("CANONICAL STRING: ", check.Canonical)
+	fmt.Println("EXPECTED SIGNATURE: ", check.Expected)
+	fmt.Println("GIVEN SIGNATURE:    ", check.Given)
+	fmt.Println("EXPIRES: ", check.Expires.UTC())
+
+	q := r.URL.Query()
+	if akey != "" && q.Get("api_key") != akey {
+		fmt.Println("MISMATCH: api_key in the query differs from specified")
+	}
+	if check.Expired {
+		fmt.Printf("MISMATCH: the request expired %v ago\n", time.Since(check.Expires).Round(time.Second))
+	}
+	if check.Expected != check.Given {
+		explain(r, body, skey, check.Given)
+	}
+	if check.Valid {
+		fmt.Println("VALID")
+	}
+	return check.Valid
+}
+
+// explain signs the common mistakes of the request and reports the ones giving the same signature
+func explain(r *http.Request, body []byte, skey, given string) {
+	q := r.URL.Query()
+	q.Del("signature")
+	variants := []struct {
+		reason    string
+		canonical string
+	}{
+		{"the parameters were not sorted by keys", r.Method + r.URL.Path + rawParams(r.URL.RawQuery) + string(body)},
+		{"the body was not signed", oo.CanonicalString(r.Method, r.URL.Path, q, nil)},
+		{"the body was signed with the trailing new line trimmed", oo.CanonicalString(r.Method, r.URL.Path, q, bytes.TrimRight(body, "\r\n"))},
+		{"the path was signed with the trailing slash toggled", oo.CanonicalString(r.Method, toggleSlash(r.URL.Path), q, body)},
+		{"the parameters were signed url encoded", r.Method + r.URL.Path + encodedParams(q) + string(body)},
+		{"only the first value of the repeated parameters was signed", oo.CanonicalString(r.Method, r.URL.Path, firstValues(q), body)},
+	}
+	for _, v := range variants {
+		if oo.Signature(skey, v.canonical) == given {
+			fmt.Println("MISMATCH: ", v.reason)
+			fmt.Println("SIGNED STRING: ", v.canonical)
+			return
+		}
+	}
+	fmt.Println("MISMATCH: the signature was computed with another secret key or the request was changed after signing")
+}
+
+// rawParams concatenates the parameters in the order of the query without the signature
+func rawParams(raw string) string {
+	var result string
+	for _, pair := range strings.Split(raw, "&") {
+		kv := strings.SplitN(pair, "=", 2)
+		if kv[0] == "signature" || kv[0] == "" {
+			continue
+		}
+		k, _ := url.QueryUnescape(kv[0])
+		var v string
+		if len(kv) == 2 {
+			v, _ = url.QueryUnescape(kv[1])
+		}
+		result += k + "=" + v
+	}
+	return result
+}
+
+// encodedParams concatenates the sorted parameters keeping the url encoding of the values
+func encodedParams(q url.Values) string {
+	var result string
+	for _, pair := range strings.Split(q.Encode(), "&") {
+		result += pair
+	}
+	return result
+}
+
+func firstValues(q url.Values) url.Values {
+	v := url.Values{}
+	for key, vals := range q {
+		v.Set(key, vals[0])
+	}
+	return v
+}
+
+func toggleSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path + "/"
+}
@@ -1,14 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/dimdiden/oo"
@@ -27,47 +23,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15)
+	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15, oo.WithRateLimit(oo.RateLimitThreshold, time.Minute))
 	if *verbose {
 		ooClient.SetLogOut(os.Stdout)
 	}
 
+	updatedAt := time.Date(2018, time.December, 5, 9, 0, 0, 0, time.UTC)
+	query := oo.NewQuery().Where(
+		oo.Field("status").Eq("live"),
+		oo.Metadata("video").Eq("test"),
+		oo.Field("updated_at").Gt(updatedAt),
+	)
+
 	for {
-		response, err := ooClient.Get(`/v2/assets?where=status='live'+AND+metadata.video='test'+AND+updated_at>'2018-12-05T09:00:00Z'`)
+		assets, err := ooClient.FindAssets(query)
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer response.Body.Close()
-
-		if response.StatusCode != http.StatusOK {
-			result, _ := ioutil.ReadAll(response.Body)
-			log.Fatalf("request failed: [%v] [%v]", response.StatusCode, string(result))
-		}
-
-		type data struct {
-			Assets []oo.Asset `json:"items"`
-		}
-		var d data
-
-		decoder := json.NewDecoder(response.Body)
-		if err := decoder.Decode(&d); err != nil {
-			log.Fatal(err)
-		}
 
-		if len(d.Assets) > 0 {
-			log.Println(d.Assets)
+		if len(assets) > 0 {
+			log.Println(assets)
 			break
 		}
-
-		credits, err := strconv.Atoi(response.Header.Get("X-RateLimit-Credits"))
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		timer := time.NewTimer(1 * time.Minute)
-		if credits < 100 {
-			fmt.Println("Only 100 credits left. Waiting for 1 min...")
-			<-timer.C
-		}
+		// the client holds the next request by itself when the credits run out
+		fmt.Println("credits left: ", ooClient.RateLimit().Credits)
 	}
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dimdiden/oo"
+)
+
+// The manifest is either a JSON list of rows or CSV with the header like:
+// file,name,processing_profile,labels,thumbnail,embed_code,external_id,key1,key2
+// Only the file column is required. The labels are separated by |,
+// the columns which are not listed above are metadata keys.
+// The row with the embed code replaces the content of the asset.
+
+// row is an asset to upload from the manifest
+type row struct {
+	File              string            `json:"file"`
+	Name              string            `json:"name"`
+	ProcessingProfile string            `json:"processing_profile"`
+	Labels            []string          `json:"labels"`
+	Metadata          map[string]string `json:"metadata"`
+	Thumbnail         string            `json:"thumbnail"`
+	EmbedCode         string            `json:"embed_code"`
+	ExternalID        string            `json:"external_id"`
+}
+
+// result is a line of the report
+type result struct {
+	Row       int
+	File      string
+	EmbedCode string
+	Err       error
+}
+
+var manifestColumns = map[string]bool{
+	"file": true, "name": true, "processing_profile": true, "labels": true, "thumbnail": true, "embed_code": true, "external_id": true,
+}
+
+func readManifest(path string) ([]row, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []row
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("couldn't read manifest: %v", err)
+		}
+		return rows, nil
+	}
+	return readCSVManifest(f)
+}
+
+func readCSVManifest(r io.Reader) ([]row, error) {
+	lines, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read manifest: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("the manifest is empty")
+	}
+	header := lines[0]
+	hasFile := false
+	for _, key := range header {
+		if key == "file" {
+			hasFile = true
+		}
+	}
+	if !hasFile {
+		return nil, fmt.Errorf("file column is absent in the header")
+	}
+
+	rows := make([]row, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		var r row
+		for i, key := range header {
+			if i >= len(line) || line[i] == "" {
+				continue
+			}
+			value := line[i]
+			switch key {
+			case "file":
+				r.File = value
+			case "name":
+				r.Name = value
+			case "processing_profile":
+				r.ProcessingProfile = value
+			case "labels":
+				r.Labels = strings.Split(value, "|")
+			case "thumbnail":
+				r.Thumbnail = value
+			case "embed_code":
+				r.EmbedCode = value
+			case "external_id":
+				r.ExternalID = value
+			}
+			if !manifestColumns[key] {
+				if r.Metadata == nil {
+					r.Metadata = map[string]string{}
+				}
+				r.Metadata[key] = value
+			}
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+// batch uploads the rows of the manifest with the given number of assets at the same time
+type batch struct {
+	client    *oo.Client
+	chunksize int
+	parallel  int
+	dedupe    oo.DedupeOptions
+	// labelMu prevents the same missing label from being created by the parallel rows
+	labelMu sync.Mutex
+}
+
+func (b *batch) run(rows []row, assets int) []result {
+	if assets < 1 {
+		assets = 1
+	}
+	results := make([]result, len(rows))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < assets; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ec, err := b.upload(rows[i])
+				results[i] = result{Row: i + 1, File: rows[i].File, EmbedCode: ec, Err: err}
+				if oo.IsDuplicate(err) {
+					fmt.Printf("row %v %v is skipped: %v\n", i+1, rows[i].File, err)
+					continue
+				}
+				if err != nil {
+					fmt.Printf("row %v %v failed: %v\n", i+1, rows[i].File, err)
+					continue
+				}
+				fmt.Printf("row %v %v has been uploaded, embed code: %v\n", i+1, rows[i].File, ec)
+			}
+		}()
+	}
+	for i := range rows {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// upload returns the embed code even if the asset was created but the later steps failed
+func (b *batch) upload(r row) (string, error) {
+	if r.File == "" {
+		return "", fmt.Errorf("no file specified")
+	}
+	f, err := os.Open(r.File)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Uploader keeps the state of a single upload, so every row gets its own
+	uploader := oo.NewUploader(b.client)
+	uploader.SetConcurrency(b.parallel)
+	if r.ProcessingProfile != "" {
+		uploader.SetPP(r.ProcessingProfile)
+	}
+	dedupe := b.dedupe
+	dedupe.ExternalID = r.ExternalID
+	uploader.SetDedupe(dedupe)
+
+	var asset *oo.Asset
+	if r.EmbedCode == "" {
+		asset, err = uploader.CreateUploadAsset(f, r.Name, b.chunksize)
+	} else {
+		asset, err = uploader.ReplaceUploadAsset(f, b.chunksize, r.EmbedCode)
+	}
+	var dup *oo.DuplicateError
+	if errors.As(err, &dup) {
+		return dup.Existing.EmbedCode, err
+	}
+	if err != nil {
+		return r.EmbedCode, err
+	}
+	ec := asset.EmbedCode
+
+	// the dedupe sets the external id only when the assets are matched by it
+	if r.ExternalID != "" && asset.ExternalID != r.ExternalID {
+		if _, err := b.client.PatchAsset(ec, oo.AssetPatch{ExternalID: oo.StringPtr(r.ExternalID)}); err != nil {
+			return ec, fmt.Errorf("couldn't set external id: %v", err)
+		}
+	}
+	if len(r.Labels) > 0 {
+		b.labelMu.Lock()
+		err := b.client.AddAssetLabelPaths(ec, r.Labels...)
+		b.labelMu.Unlock()
+		if err != nil {
+			return ec, fmt.Errorf("couldn't add labels: %v", err)
+		}
+	}
+	if len(r.Metadata) > 0 {
+		if _, err := b.client.PatchMetadata(ec, oo.CustomMetadata(r.Metadata)); err != nil {
+			return ec, fmt.Errorf("couldn't set metadata: %v", err)
+		}
+	}
+	if r.Thumbnail != "" {
+		thumb, err := os.Open(r.Thumbnail)
+		if err != nil {
+			return ec, fmt.Errorf("couldn't open thumbnail: %v", err)
+		}
+		defer thumb.Close()
+		if err := uploader.UploadImage(thumb, ec); err != nil {
+			return ec, fmt.Errorf("couldn't upload thumbnail: %v", err)
+		}
+	}
+	return ec, nil
+}
+
+func writeReport(path string, results []result) error {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := csv.NewWriter(out)
+	w.Write([]string{"row", "file", "embed_code", "status", "error"})
+	for _, r := range results {
+		status, msg := "ok", ""
+		if oo.IsDuplicate(r.Err) {
+			status, msg = "skipped", r.Err.Error()
+		} else if r.Err != nil {
+			status, msg = "failed", r.Err.Error()
+		}
+		w.Write([]string{strconv.Itoa(r.Row), r.File, r.EmbedCode, status, msg})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// parseDedupe reads the dedupe policy and the comma separated match types
+func parseDedupe(policy, match string) (oo.DedupeOptions, error) {
+	var opts oo.DedupeOptions
+	switch policy {
+	case "":
+		return opts, nil
+	case "skip":
+		opts.Policy = oo.DedupeSkip
+	case "replace":
+		opts.Policy = oo.DedupeReplace
+	case "create":
+		opts.Policy = oo.DedupeCreate
+	default:
+		return opts, fmt.Errorf("unknown dedupe policy %v", policy)
+	}
+	for _, m := range strings.Split(match, ",") {
+		switch strings.TrimSpace(m) {
+		case "name":
+			opts.Match |= oo.MatchFileName
+		case "external_id":
+			opts.Match |= oo.MatchExternalID
+		case "hash":
+			opts.Match |= oo.MatchContentHash
+		default:
+			return opts, fmt.Errorf("unknown dedupe match %v", m)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimdiden/oo/oootest"
+)
+
+func TestBatchExternalIDWithoutDedupe(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "uploadtool-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	video := filepath.Join(dir, "video.mp4")
+	if err := ioutil.WriteFile(video, bytes.Repeat([]byte("video"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &batch{client: srv.Client(), chunksize: 2048, parallel: 1}
+	ec, err := b.upload(row{File: video, Name: "video", ExternalID: "ext-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, ok := srv.Asset(ec)
+	if !ok || a.ExternalID != "ext-1" {
+		t.Errorf("asset %v has external id %q, want ext-1", ec, a.ExternalID)
+	}
+}
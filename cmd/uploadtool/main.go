@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/dimdiden/oo"
 )
@@ -14,19 +17,34 @@ const chunkSizeDefault int = 100
 func main() {
 	// All variables needed for upload
 	var (
-		api     string
-		secret  string
-		file    string
-		ecode   string
-		name    string
-		pp      string
-		chunk   int
-		verbose bool
+		api      string
+		secret   string
+		file     string
+		ecode    string
+		name     string
+		pp       string
+		state    string
+		chunk    int
+		parallel int
+		memory   int
+		size     int64
+		md5      bool
+		md5Hdr   bool
+		sha      bool
+		verify   bool
+		wait     bool
+		timeout  time.Duration
+		manifest string
+		report   string
+		assets   int
+		dedupe   string
+		match    string
+		verbose  bool
 	)
 	// The root usage
 	flag.Usage = func() {
 		fmt.Println("usage: uploadtool <command> [<args>]")
-		fmt.Println("use image or asset for <command>")
+		fmt.Println("use image, asset or batch for <command>")
 		flag.PrintDefaults()
 	}
 	// List of flags for image subcommand
@@ -40,12 +58,34 @@ func main() {
 	assetCommand := flag.NewFlagSet("asset", flag.ExitOnError)
 	assetCommand.StringVar(&api, "a", "", "specify api key")
 	assetCommand.StringVar(&secret, "s", "", "specify secret key")
-	assetCommand.StringVar(&file, "f", "", "specify path to the video file")
+	assetCommand.StringVar(&file, "f", "", "specify path to the video file, - reads the video from stdin")
+	assetCommand.Int64Var(&size, "size", 0, "[optional] specify the size in bytes of the video read from stdin")
 	assetCommand.StringVar(&ecode, "e", "", "[optional] specify embed code for the content-replacement procedure")
 	assetCommand.StringVar(&name, "n", "", "[optional] specify the asset name")
 	assetCommand.StringVar(&pp, "pp", "", "[optional] specify processing profile id")
 	assetCommand.IntVar(&chunk, "ch", chunkSizeDefault, "[optional] specify the chunk size in MB")
+	assetCommand.IntVar(&parallel, "p", oo.DefaultConcurrency, "[optional] specify the number of chunks uploaded at the same time")
+	assetCommand.IntVar(&memory, "mem", 0, "[optional] specify the memory limit for the chunks in MB, 0 means chunk size times -p")
+	assetCommand.BoolVar(&md5, "md5", false, "[optional] compute md5 of the chunks and the file")
+	assetCommand.BoolVar(&md5Hdr, "content-md5", false, "[optional] send the chunks with Content-MD5 header, so the corrupted ones are rejected")
+	assetCommand.BoolVar(&sha, "sha256", false, "[optional] compute sha256 of the chunks and the file")
+	assetCommand.BoolVar(&verify, "verify", false, "[optional] compare the uploaded file with the one reported by Backlot")
+	assetCommand.BoolVar(&wait, "wait", false, "[optional] wait until the asset processing is finished and report the final status")
+	assetCommand.DurationVar(&timeout, "timeout", 0, "[optional] specify how long to wait for the processing, 0 means no limit")
+	assetCommand.StringVar(&state, "state", "", "[optional] specify the file to save the progress to, the interrupted upload is resumed from it")
 	assetCommand.BoolVar(&verbose, "v", false, "verbose mode")
+	// List of flags for batch subcommand
+	batchCommand := flag.NewFlagSet("batch", flag.ExitOnError)
+	batchCommand.StringVar(&api, "a", "", "specify api key")
+	batchCommand.StringVar(&secret, "s", "", "specify secret key")
+	batchCommand.StringVar(&manifest, "m", "", "specify path to the CSV or JSON manifest of the assets")
+	batchCommand.StringVar(&report, "o", "", "[optional] specify path to the CSV report, the report is printed if not set")
+	batchCommand.IntVar(&assets, "j", 2, "[optional] specify the number of assets uploaded at the same time")
+	batchCommand.IntVar(&chunk, "ch", chunkSizeDefault, "[optional] specify the chunk size in MB")
+	batchCommand.IntVar(&parallel, "p", oo.DefaultConcurrency, "[optional] specify the number of chunks of every asset uploaded at the same time")
+	batchCommand.StringVar(&dedupe, "dedupe", "", "[optional] look for the existing asset first and skip, replace or create it anyway")
+	batchCommand.StringVar(&match, "match", "name", "[optional] specify how the existing assets are matched: name, external_id, hash or the list of them")
+	batchCommand.BoolVar(&verbose, "v", false, "verbose mode")
 	// Check if subcommands are provided
 	if len(os.Args) < 2 {
 		flag.Usage()
@@ -57,6 +97,8 @@ func main() {
 		imageCommand.Parse(os.Args[2:])
 	case "asset":
 		assetCommand.Parse(os.Args[2:])
+	case "batch":
+		batchCommand.Parse(os.Args[2:])
 	default:
 		flag.Usage()
 		os.Exit(1)
@@ -67,10 +109,37 @@ func main() {
 		ooClient.SetLogOut(os.Stdout)
 	}
 
+	// Batch flags validation
+	if batchCommand.Parsed() {
+		if manifest == "" || secret == "" || api == "" {
+			batchCommand.PrintDefaults()
+			os.Exit(1)
+		}
+		rows, err := readManifest(manifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts, err := parseDedupe(dedupe, match)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b := &batch{client: ooClient, chunksize: chunk * 1024 * 1024, parallel: parallel, dedupe: opts}
+		results := b.run(rows, assets)
+		if err := writeReport(report, results); err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Err != nil && !oo.IsDuplicate(r.Err) {
+				os.Exit(1)
+			}
+		}
+		return
+	}
+
 	uploader := oo.NewUploader(ooClient)
 	bars := newBars()
 	uploader.SetStartFunc(bars.Start)
-	uploader.SetFilterFunc(bars.filterFunc)
+	uploader.SetEventFunc(bars.eventFunc)
 	uploader.SetDeferFunc(bars.deferFunc)
 
 	// Image flags validation
@@ -99,29 +168,88 @@ func main() {
 			os.Exit(1)
 		}
 
-		f, err := os.Open(file)
-		if err != nil {
-			log.Fatal(err)
+		var (
+			source *oo.Source
+			err    error
+		)
+		if file == "-" {
+			if size <= 0 || name == "" && ecode == "" {
+				fmt.Println("-size and -n are required to upload from stdin")
+				os.Exit(1)
+			}
+			source = oo.NewStreamSource(os.Stdin, size, name)
+		} else {
+			f, err := os.Open(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			if source, err = oo.FileSource(f); err != nil {
+				log.Fatal(err)
+			}
 		}
-		defer f.Close()
 
 		if pp != "" {
 			uploader.SetPP(pp)
 		}
+		if state != "" {
+			uploader.SetStatePath(state)
+		}
+		uploader.SetConcurrency(parallel)
+		uploader.SetMemoryLimit(int64(memory) * 1024 * 1024)
+		var checksums oo.ChecksumType
+		if md5 {
+			checksums |= oo.MD5
+		}
+		if sha {
+			checksums |= oo.SHA256
+		}
+		uploader.SetChecksums(checksums)
+		uploader.SetContentMD5(md5Hdr)
+		uploader.SetVerify(verify)
 
 		chunksize := chunk * 1024 * 1024
+		var asset *oo.Asset
 		if ecode == "" {
-			asset, err := uploader.CreateUploadAsset(f, name, chunksize)
-			if err != nil {
+			asset, err = uploader.CreateUploadSource(source, name, chunksize)
+			if err != nil && !errors.Is(err, oo.ErrCannotVerify) {
 				log.Fatal(err)
 			}
 			fmt.Println("Video has been uploaded, embed code: ", asset.EmbedCode)
 		} else {
-			asset, err := uploader.ReplaceUploadAsset(f, chunksize, ecode)
-			if err != nil {
+			asset, err = uploader.ReplaceUploadSource(source, chunksize, ecode)
+			if err != nil && !errors.Is(err, oo.ErrCannotVerify) {
 				log.Fatal(err)
 			}
 			fmt.Println("Video has replaced for embed code: ", asset.EmbedCode)
 		}
+		if err != nil {
+			fmt.Println("Warning: ", err)
+		}
+		printChecksums(asset.Checksums())
+
+		if wait {
+			opts := oo.WaitOptions{
+				Timeout:  timeout,
+				OnStatus: func(a *oo.Asset) { fmt.Println("Status: ", a.Status) },
+			}
+			final, err := ooClient.WatchProcessing(context.Background(), asset.EmbedCode, opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("Processing has finished, status: ", final.Status)
+		}
+	}
+}
+
+func printChecksums(sums *oo.Checksums) {
+	if sums == nil {
+		return
+	}
+	if sums.MD5 != "" {
+		fmt.Println("md5:    ", sums.MD5)
+	}
+	if sums.SHA256 != "" {
+		fmt.Println("sha256: ", sums.SHA256)
 	}
 }
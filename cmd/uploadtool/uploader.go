@@ -1,25 +1,36 @@
 package main
 
 import (
-	"net/http"
-
+	"github.com/dimdiden/oo"
 	pb "gopkg.in/cheggaaa/pb.v1"
 )
 
 type bars struct {
 	*pb.Pool
+	chunks map[int]*pb.ProgressBar
 }
 
 func newBars() *bars {
-	return &bars{pb.NewPool()}
+	return &bars{Pool: pb.NewPool(), chunks: make(map[int]*pb.ProgressBar)}
 }
 
-func (b *bars) filterFunc(r *http.Request) (*http.Request, error) {
-	bar := pb.StartNew(int(r.ContentLength)).SetUnits(pb.U_BYTES)
-	b.Add(bar)
-	reader := bar.NewProxyReader(r.Body)
-	r.Body = reader
-	return r, nil
+// eventFunc draws a bar per chunk, the events come one at a time
+func (b *bars) eventFunc(e oo.Event) {
+	switch e.Type {
+	case oo.ChunkStarted:
+		bar := pb.New64(e.ChunkSize).SetUnits(pb.U_BYTES)
+		b.chunks[e.Chunk] = bar
+		b.Add(bar)
+	case oo.ChunkProgress, oo.ChunkRetried:
+		if bar, ok := b.chunks[e.Chunk]; ok {
+			bar.Set64(e.ChunkSent)
+		}
+	case oo.ChunkCompleted:
+		if bar, ok := b.chunks[e.Chunk]; ok {
+			bar.Set64(e.ChunkSize)
+			bar.Finish()
+		}
+	}
 }
 
 func (b *bars) deferFunc() {
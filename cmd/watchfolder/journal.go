@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// entry is a line of the journal about the ingested file
+type entry struct {
+	File      string    `json:"file"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"mod_time"`
+	EmbedCode string    `json:"embed_code,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// key identifies the content of the file, so the new file with the same name is ingested again
+func (e entry) key() string {
+	return fmt.Sprintf("%v|%v|%v", e.File, e.Size, e.ModTime.UnixNano())
+}
+
+// journal is the append only log of the ingested files.
+// It survives the restarts, so the file is never uploaded twice
+type journal struct {
+	path    string
+	entries map[string]entry
+}
+
+func openJournal(path string) (*journal, error) {
+	j := &journal{path: path, entries: make(map[string]entry)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// the last line could be cut by a crash
+			continue
+		}
+		j.entries[e.key()] = e
+	}
+	return j, scanner.Err()
+}
+
+// lookup returns the last entry about the same file
+func (j *journal) lookup(name string, size int64, modTime time.Time) (entry, bool) {
+	e, ok := j.entries[entry{File: name, Size: size, ModTime: modTime}.key()]
+	return e, ok
+}
+
+// add appends the entry to the journal file
+func (j *journal) add(e entry) error {
+	e.Time = time.Now()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	j.entries[e.key()] = e
+	return f.Sync()
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dimdiden/oo"
+)
+
+// The tool polls the directory and uploads every new file once its size and modification time
+// stay the same for the -stable period. The optional sidecar file video.mp4.json or video.json
+// describes the asset:
+// {"name": "Title", "processing_profile": "id", "labels": ["/Sports"], "metadata": {"key": "value"}}
+// The uploaded files are moved to the done folder, the failed ones to the failed folder
+// together with their sidecars. The journal remembers the ingested files across the restarts,
+// and the interrupted upload is resumed from its state file.
+
+// sidecar describes the asset of the uploaded file
+type sidecar struct {
+	Name              string            `json:"name"`
+	ProcessingProfile string            `json:"processing_profile"`
+	Labels            []string          `json:"labels"`
+	Metadata          map[string]string `json:"metadata"`
+	// EmbedCode replaces the content of the existing asset
+	EmbedCode string `json:"embed_code"`
+}
+
+// observation is the state of the file seen by the last poll
+type observation struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+type watcher struct {
+	client    *oo.Client
+	dir       string
+	doneDir   string
+	failedDir string
+	stateDir  string
+	stable    time.Duration
+	chunksize int
+	parallel  int
+	pp        string
+	journal   *journal
+	seen      map[string]observation
+}
+
+func main() {
+	// Flag block
+	secret := flag.String("s", "", "specify secret key")
+	api := flag.String("a", "", "specify api key")
+	dir := flag.String("d", "", "specify the directory to watch")
+	done := flag.String("done", "", "[optional] specify the directory for the uploaded files, <dir>/done by default")
+	failed := flag.String("failed", "", "[optional] specify the directory for the failed files, <dir>/failed by default")
+	journalPath := flag.String("journal", "", "[optional] specify the journal file, <dir>/.journal by default")
+	interval := flag.Duration("i", 10*time.Second, "[optional] specify the polling interval")
+	stable := flag.Duration("stable", 30*time.Second, "[optional] specify how long the file must stay unchanged before the upload")
+	chunk := flag.Int("ch", 100, "[optional] specify the chunk size in MB")
+	parallel := flag.Int("p", oo.DefaultConcurrency, "[optional] specify the number of chunks uploaded at the same time")
+	pp := flag.String("pp", "", "[optional] specify the default processing profile id")
+	verbose := flag.Bool("v", false, "verbose mode")
+	flag.Parse()
+
+	if *secret == "" || *api == "" || *dir == "" {
+		fmt.Println("Incorrect usage, please specify the required parameters")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	ooClient, _ := oo.NewClient(*secret, *api, oo.BacklotDefaultEndpoint, 15)
+	if *verbose {
+		ooClient.SetLogOut(os.Stdout)
+	}
+
+	w := &watcher{
+		client:    ooClient,
+		dir:       *dir,
+		doneDir:   orDefault(*done, filepath.Join(*dir, "done")),
+		failedDir: orDefault(*failed, filepath.Join(*dir, "failed")),
+		stateDir:  filepath.Join(*dir, ".state"),
+		stable:    *stable,
+		chunksize: *chunk * 1024 * 1024,
+		parallel:  *parallel,
+		pp:        *pp,
+		seen:      make(map[string]observation),
+	}
+	for _, d := range []string{w.doneDir, w.failedDir, w.stateDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	j, err := openJournal(orDefault(*journalPath, filepath.Join(*dir, ".journal")))
+	if err != nil {
+		log.Fatal("could not read journal: ", err)
+	}
+	w.journal = j
+
+	// the upload in progress is interrupted by the signal and resumed after the restart
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-signals
+		log.Printf("%v received, stopping", s)
+		cancel()
+	}()
+
+	log.Printf("watching %v", w.dir)
+	for {
+		if err := w.poll(ctx); err != nil {
+			log.Printf("poll failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// poll uploads the files which stay unchanged long enough
+func (w *watcher) poll(ctx context.Context) error {
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	present := make(map[string]bool)
+	var ready []os.FileInfo
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || skipped(name) {
+			continue
+		}
+		present[name] = true
+		obs, ok := w.seen[name]
+		if !ok || obs.size != info.Size() || !obs.modTime.Equal(info.ModTime()) {
+			w.seen[name] = observation{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(obs.since) >= w.stable {
+			ready = append(ready, info)
+		}
+	}
+	for name := range w.seen {
+		if !present[name] {
+			delete(w.seen, name)
+		}
+	}
+
+	sort.Slice(ready, func(i, j int) bool { return ready[i].Name() < ready[j].Name() })
+	for _, info := range ready {
+		if ctx.Err() != nil {
+			return nil
+		}
+		w.ingest(ctx, info)
+	}
+	return nil
+}
+
+// skipped are the hidden, partial and sidecar files
+func skipped(name string) bool {
+	return strings.HasPrefix(name, ".") ||
+		strings.HasSuffix(name, ".part") ||
+		strings.HasSuffix(name, ".tmp") ||
+		strings.HasSuffix(name, ".json")
+}
+
+func (w *watcher) ingest(ctx context.Context, info os.FileInfo) {
+	name := info.Name()
+	// the previous run could stop after the upload but before moving the file.
+	// The failed file put back to the folder is uploaded again unless its asset exists
+	prev, ok := w.journal.lookup(name, info.Size(), info.ModTime())
+	if ok && prev.Status == "done" {
+		w.move(name, true)
+		return
+	}
+
+	var ec string
+	var err error
+	if ok && prev.EmbedCode != "" {
+		// the asset exists already, only the labels and metadata are left
+		log.Printf("finishing %v, embed code: %v", name, prev.EmbedCode)
+		ec = prev.EmbedCode
+		err = w.finish(ctx, name, ec)
+	} else {
+		log.Printf("uploading %v", name)
+		ec, err = w.upload(ctx, info)
+	}
+	if ctx.Err() != nil && ec == "" {
+		// keep the file and the upload state for the next run
+		log.Printf("upload of %v is interrupted", name)
+		return
+	}
+	if ctx.Err() != nil {
+		// the journal has the uploaded entry, the next run sets the labels and metadata
+		log.Printf("%v is uploaded as %v but interrupted before labels and metadata", name, ec)
+		return
+	}
+
+	e := entry{File: name, Size: info.Size(), ModTime: info.ModTime(), EmbedCode: ec, Status: "done"}
+	if err != nil {
+		e.Status, e.Error = "failed", err.Error()
+		log.Printf("%v failed: %v", name, err)
+		os.Remove(w.statePath(name))
+	} else {
+		log.Printf("%v has been uploaded, embed code: %v", name, ec)
+	}
+	if err := w.journal.add(e); err != nil {
+		log.Printf("could not write journal: %v", err)
+	}
+	w.move(name, err == nil)
+	delete(w.seen, name)
+}
+
+// upload returns the embed code only if the asset is uploaded.
+// The uploaded entry is written to the journal right away, so the file isn't uploaded again
+// if the labels or metadata are interrupted
+func (w *watcher) upload(ctx context.Context, info os.FileInfo) (string, error) {
+	name := info.Name()
+	meta, err := w.sidecar(name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(w.dir, name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	uploader := oo.NewUploader(w.client)
+	uploader.SetConcurrency(w.parallel)
+	uploader.SetStatePath(w.statePath(name))
+	if pp := orDefault(meta.ProcessingProfile, w.pp); pp != "" {
+		uploader.SetPP(pp)
+	}
+
+	var asset *oo.Asset
+	if meta.EmbedCode == "" {
+		asset, err = uploader.CreateUploadAssetContext(ctx, f, meta.Name, w.chunksize)
+	} else {
+		asset, err = uploader.ReplaceUploadAssetContext(ctx, f, w.chunksize, meta.EmbedCode)
+	}
+	if err != nil {
+		return "", err
+	}
+	e := entry{File: name, Size: info.Size(), ModTime: info.ModTime(), EmbedCode: asset.EmbedCode, Status: "uploaded"}
+	if err := w.journal.add(e); err != nil {
+		log.Printf("could not write journal: %v", err)
+	}
+	return asset.EmbedCode, w.finish(ctx, name, asset.EmbedCode)
+}
+
+// finish sets the labels and metadata of the sidecar for the uploaded asset
+func (w *watcher) finish(ctx context.Context, name, ec string) error {
+	meta, err := w.sidecar(name)
+	if err != nil {
+		return err
+	}
+	if len(meta.Labels) > 0 {
+		if err := w.client.AddAssetLabelPathsContext(ctx, ec, meta.Labels...); err != nil {
+			return fmt.Errorf("couldn't add labels: %v", err)
+		}
+	}
+	if len(meta.Metadata) > 0 {
+		if _, err := w.client.PatchMetadataContext(ctx, ec, oo.CustomMetadata(meta.Metadata)); err != nil {
+			return fmt.Errorf("couldn't set metadata: %v", err)
+		}
+	}
+	return nil
+}
+
+func (w *watcher) statePath(name string) string {
+	return filepath.Join(w.stateDir, name+".state")
+}
+
+// sidecarPath returns the sidecar of the file if it exists
+func (w *watcher) sidecarPath(name string) string {
+	for _, p := range []string{name + ".json", strings.TrimSuffix(name, filepath.Ext(name)) + ".json"} {
+		if _, err := os.Stat(filepath.Join(w.dir, p)); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+func (w *watcher) sidecar(name string) (sidecar, error) {
+	var meta sidecar
+	p := w.sidecarPath(name)
+	if p == "" {
+		return meta, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(w.dir, p))
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, fmt.Errorf("couldn't read sidecar %v: %v", p, err)
+	}
+	return meta, nil
+}
+
+// move puts the file and its sidecar to the done or failed folder
+func (w *watcher) move(name string, ok bool) {
+	target := w.failedDir
+	if ok {
+		target = w.doneDir
+	}
+	files := []string{name}
+	if p := w.sidecarPath(name); p != "" {
+		files = append(files, p)
+	}
+	for _, f := range files {
+		if err := os.Rename(filepath.Join(w.dir, f), filepath.Join(target, f)); err != nil {
+			log.Printf("could not move %v: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimdiden/oo"
+	"github.com/dimdiden/oo/oootest"
+)
+
+func newTestWatcher(t *testing.T, client *oo.Client, dir string) *watcher {
+	t.Helper()
+	w := &watcher{
+		client:    client,
+		dir:       dir,
+		doneDir:   filepath.Join(dir, "done"),
+		failedDir: filepath.Join(dir, "failed"),
+		stateDir:  filepath.Join(dir, ".state"),
+		chunksize: 1024,
+		parallel:  1,
+		seen:      make(map[string]observation),
+	}
+	for _, d := range []string{w.doneDir, w.failedDir, w.stateDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j, err := openJournal(filepath.Join(dir, ".journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.journal = j
+	return w
+}
+
+func TestCancelAfterUpload(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "watchfolder-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := bytes.Repeat([]byte("video"), 1000)
+	if err := ioutil.WriteFile(filepath.Join(dir, "video.mp4"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := `{"name": "Title", "metadata": {"key": "value"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "video.mp4.json"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the daemon is stopped when the metadata is being set after the upload
+	ctx, cancel := context.WithCancel(context.Background())
+	client := srv.Client(oo.WithMiddleware(func(rt http.RoundTripper) http.RoundTripper {
+		return oo.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, "/metadata") {
+				cancel()
+				return nil, ctx.Err()
+			}
+			return rt.RoundTrip(r)
+		})
+	}))
+	w := newTestWatcher(t, client, dir)
+	// the first poll only sees the file, the second one finds it unchanged
+	for i := 0; i < 2; i++ {
+		if err := w.poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "video.mp4")); err != nil {
+		t.Fatalf("the interrupted file is moved: %v", err)
+	}
+	const ec = "oootest00000001"
+	info, _ := os.Stat(filepath.Join(dir, "video.mp4"))
+	if e, ok := w.journal.lookup("video.mp4", info.Size(), info.ModTime()); !ok || e.Status != "uploaded" || e.EmbedCode != ec {
+		t.Fatalf("journal entry is %+v, want uploaded %v", e, ec)
+	}
+
+	// the restarted daemon reads the journal and only sets the metadata
+	w = newTestWatcher(t, srv.Client(), dir)
+	for i := 0; i < 2; i++ {
+		if err := w.poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "done", "video.mp4")); err != nil {
+		t.Errorf("the file isn't moved to done: %v", err)
+	}
+	if _, ok := srv.Asset("oootest00000002"); ok {
+		t.Error("the file is uploaded again")
+	}
+	if !bytes.Equal(srv.Content(ec), content) {
+		t.Error("the uploaded content differs from the file")
+	}
+	m, err := srv.Client().GetMetadata(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["key"] != "value" {
+		t.Errorf("metadata is %v, want key=value", m)
+	}
+	if e, ok := w.journal.lookup("video.mp4", info.Size(), info.ModTime()); !ok || e.Status != "done" {
+		t.Errorf("journal entry is %+v, want done", e)
+	}
+}
+
+func TestRetryFinishAfterFailure(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "watchfolder-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "video.mp4"), bytes.Repeat([]byte("video"), 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := `{"metadata": {"key": "value"}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "video.mp4.json"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the metadata fails after the upload and the file is moved to failed
+	const ec = "oootest00000001"
+	srv.Fail("", "/v2/assets/"+ec+"/metadata", http.StatusBadRequest, 1)
+	w := newTestWatcher(t, srv.Client(), dir)
+	for i := 0; i < 2; i++ {
+		if err := w.poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failed", "video.mp4")); err != nil {
+		t.Fatalf("the file isn't moved to failed: %v", err)
+	}
+
+	// the file put back to the folder reuses the uploaded asset
+	for _, name := range []string{"video.mp4", "video.mp4.json"} {
+		if err := os.Rename(filepath.Join(dir, "failed", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "done", "video.mp4")); err != nil {
+		t.Errorf("the file isn't moved to done: %v", err)
+	}
+	if _, ok := srv.Asset("oootest00000002"); ok {
+		t.Error("the file is uploaded again")
+	}
+	m, err := srv.Client().GetMetadata(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["key"] != "value" {
+		t.Errorf("metadata is %v, want key=value", m)
+	}
+}
@@ -0,0 +1,176 @@
+package oo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DedupePolicy tells what to do when the asset with the same content already exists
+type DedupePolicy int
+
+const (
+	// DedupeOff doesn't look for the duplicates
+	DedupeOff DedupePolicy = iota
+	// DedupeSkip returns the existing asset with *DuplicateError without uploading
+	DedupeSkip
+	// DedupeReplace uploads the content as the replacement of the existing asset
+	DedupeReplace
+	// DedupeCreate reports the duplicate to the log and creates the new asset anyway
+	DedupeCreate
+)
+
+// DedupeMatch selects how the existing assets are matched, the types can be combined with |
+type DedupeMatch int
+
+const (
+	// MatchFileName matches the assets by original_file_name
+	MatchFileName DedupeMatch = 1 << iota
+	// MatchExternalID matches the assets by external_id given in the options
+	MatchExternalID
+	// MatchContentHash matches the assets by SHA256 of the content stored in the metadata.
+	// The hash of the whole source is computed before the lookup, so the source must be
+	// a file or io.ReaderAt. The hash stored after the upload is computed as the chunks are read
+	MatchContentHash
+)
+
+// DefaultHashKey is the metadata key the content hash is stored under
+const DefaultHashKey = "content_sha256"
+
+// DedupeOptions controls the lookup for the duplicates before the asset is created
+type DedupeOptions struct {
+	Policy DedupePolicy
+	Match  DedupeMatch
+	// ExternalID is matched with MatchExternalID and set to the created asset.
+	// It belongs to a single upload, so it should be changed before the next one
+	ExternalID string
+	// HashKey is the metadata key of the content hash, DefaultHashKey if empty
+	HashKey string
+}
+
+// DuplicateError is returned by DedupeSkip policy with the asset which already exists
+type DuplicateError struct {
+	Existing *Asset
+	// MatchedBy is the field the asset is matched by
+	MatchedBy string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("asset %v with the same %v already exists", e.Existing.EmbedCode, e.MatchedBy)
+}
+
+// IsDuplicate reports if err is caused by the existing asset skipped by the dedupe
+func IsDuplicate(err error) bool {
+	var e *DuplicateError
+	return errors.As(err, &e)
+}
+
+// SetDedupe enables the lookup for the existing asset before the new one is created
+func (u *Uploader) SetDedupe(opts DedupeOptions) {
+	if opts.HashKey == "" {
+		opts.HashKey = DefaultHashKey
+	}
+	u.dedupe = opts
+}
+
+// FindDuplicate returns the existing asset matching the source by the given options
+// and the name of the matched field, nil if there is no such asset
+func (c Client) FindDuplicate(ctx context.Context, source *Source, opts DedupeOptions) (*Asset, string, error) {
+	if opts.HashKey == "" {
+		opts.HashKey = DefaultHashKey
+	}
+	var conds []dedupeCond
+	if opts.Match&MatchExternalID != 0 && opts.ExternalID != "" {
+		conds = append(conds, dedupeCond{"external_id", Field("external_id").Eq(opts.ExternalID)})
+	}
+	if opts.Match&MatchContentHash != 0 {
+		hash, err := contentHash(source)
+		if err != nil {
+			return nil, "", err
+		}
+		conds = append(conds, dedupeCond{"content hash", Metadata(opts.HashKey).Eq(hash)})
+	}
+	if opts.Match&MatchFileName != 0 && source.Name != "" {
+		conds = append(conds, dedupeCond{"original_file_name", Field("original_file_name").Eq(source.Name)})
+	}
+
+	// the most specific match goes first
+	for _, d := range conds {
+		q := NewQuery().Where(d.cond).OrderByDesc("created_at").Limit(1).PageSize(1)
+		assets, err := c.FindAssetsContext(ctx, q)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(assets) > 0 {
+			return &assets[0], d.field, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// dedupeCond is the lookup for the duplicate by a single field
+type dedupeCond struct {
+	field string
+	cond  Cond
+}
+
+// contentHash computes SHA256 of the whole source
+func contentHash(source *Source) (string, error) {
+	if source.readerAt == nil {
+		return "", fmt.Errorf("couldn't hash the stream before the upload, use a file or io.ReaderAt")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(source.readerAt, 0, source.Size)); err != nil {
+		return "", fmt.Errorf("couldn't hash the source: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicate applies the dedupe policy. It returns the asset to replace or
+// the error which stops the upload, both nil mean the new asset is created
+func (u *Uploader) findDuplicate(ctx context.Context, source *Source) (*Asset, error) {
+	if u.dedupe.Policy == DedupeOff || u.dedupe.Match == 0 {
+		return nil, nil
+	}
+	existing, field, err := u.client.FindDuplicate(ctx, source, u.dedupe)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+	fmt.Fprintf(u.client.out, "DEDUPE: %v matches asset %v by %v\n", source.Name, existing.EmbedCode, field)
+	switch u.dedupe.Policy {
+	case DedupeSkip:
+		return nil, &DuplicateError{Existing: existing, MatchedBy: field}
+	case DedupeReplace:
+		return existing, nil
+	}
+	return nil, nil
+}
+
+// markAsset stores the external id and the content hash in the uploaded asset,
+// so the next upload of the same content finds it
+func (u *Uploader) markAsset(ctx context.Context, asset *Asset) error {
+	if u.dedupe.Policy == DedupeOff {
+		return nil
+	}
+	if u.dedupe.Match&MatchExternalID != 0 && u.dedupe.ExternalID != "" && asset.ExternalID != u.dedupe.ExternalID {
+		if _, err := u.client.PatchAssetContext(ctx, asset.EmbedCode, AssetPatch{ExternalID: StringPtr(u.dedupe.ExternalID)}); err != nil {
+			return err
+		}
+		asset.ExternalID = u.dedupe.ExternalID
+	}
+	if u.dedupe.Match&MatchContentHash != 0 {
+		// the source is closed after the upload, so the hash is taken from the checksums
+		sums := asset.checksums
+		if sums == nil || sums.SHA256 == "" {
+			return fmt.Errorf("content hash wasn't computed during the upload")
+		}
+		m := CustomMetadata{u.dedupe.HashKey: sums.SHA256}
+		if _, err := u.client.PatchMetadataContext(ctx, asset.EmbedCode, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,11 +3,10 @@ package oo
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -38,17 +37,53 @@ type Similar struct {
 	BucketInfo string `json:"bucket_info"`
 }
 
+// UnmarshalJSON decodes the recommendation. It is needed because
+// the method of the embedded Asset would skip Reason and BucketInfo otherwise
+func (s *Similar) UnmarshalJSON(data []byte) error {
+	if err := s.Asset.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	var r struct {
+		Reason     string `json:"reason"`
+		BucketInfo string `json:"bucket_info"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	s.Reason = r.Reason
+	s.BucketInfo = r.BucketInfo
+	delete(s.Extra, "reason")
+	delete(s.Extra, "bucket_info")
+	if len(s.Extra) == 0 {
+		s.Extra = nil
+	}
+	return nil
+}
+
 // GetNewSimilars fetches list of the recommendations for the given embedCode.
 func GetNewSimilars(ci ClientInterface, embedCode string, values url.Values) (*Similars, error) {
-	query := fmt.Sprintf("/v2/discover/similar/assets/%v?%v", embedCode, values.Encode())
-	response, err := ci.Get(query)
+	if cci, ok := ci.(ContextClientInterface); ok {
+		return GetNewSimilarsContext(context.Background(), cci, embedCode, values)
+	}
+	return decodeSimilars(ci.Get(similarsPath(embedCode, values)))
+}
+
+// GetNewSimilarsContext is the same as GetNewSimilars but with the given context
+func GetNewSimilarsContext(ctx context.Context, ci ContextClientInterface, embedCode string, values url.Values) (*Similars, error) {
+	return decodeSimilars(ci.GetContext(ctx, similarsPath(embedCode, values)))
+}
+
+func similarsPath(embedCode string, values url.Values) string {
+	return fmt.Sprintf("/v2/discover/similar/assets/%v?%v", embedCode, values.Encode())
+}
+
+func decodeSimilars(response *http.Response, err error) (*Similars, error) {
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		result, _ := ioutil.ReadAll(response.Body)
-		log.Fatalf("request failed: [%v] [%v]", response.StatusCode, string(result))
+	if err := checkServiceError(response, http.StatusOK); err != nil {
+		return nil, err
 	}
 
 	var similars Similars
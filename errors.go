@@ -0,0 +1,92 @@
+package oo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is returned when Ooyala API responds with an unexpected status
+type APIError struct {
+	// StatusCode is the http status of the response
+	StatusCode int
+	// Method and Path describe the failed request
+	Method string
+	Path   string
+	// Message and Code are parsed from the JSON body of the response if present
+	Message string
+	Code    string
+	// Body is the raw body of the response
+	Body string
+	// RateLimit is the credits state reported in the response
+	RateLimit RateLimit
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	if e.Method == "" {
+		return fmt.Sprintf("service error: [%v] %v", e.StatusCode, msg)
+	}
+	return fmt.Sprintf("service error: [%v] %v %v: %v", e.StatusCode, e.Method, e.Path, msg)
+}
+
+// newAPIError builds APIError from the response and consumes its body
+func newAPIError(r *http.Response) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("could't check http error: %v", err)
+	}
+	e := &APIError{
+		StatusCode: r.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+	if r.Request != nil {
+		e.Method = r.Request.Method
+		e.Path = r.Request.URL.Path
+	}
+	if rl, ok := rateLimitFromHeader(r.Header, time.Now()); ok {
+		e.RateLimit = rl
+	}
+	// Ooyala APIs describe errors like {"message": "...", "code": ...}
+	var data struct {
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Code    json.RawMessage `json:"code"`
+	}
+	if json.Unmarshal(body, &data) == nil {
+		e.Message = data.Message
+		if e.Message == "" {
+			e.Message = data.Error
+		}
+		e.Code = strings.Trim(string(data.Code), `"`)
+	}
+	return e
+}
+
+// IsStatus reports if err is an APIError with the given http status
+func IsStatus(err error, status int) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == status
+}
+
+// IsNotFound reports if err is caused by a missing entity
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+// IsRateLimited reports if err is caused by running out of the API credits
+func IsRateLimited(err error) bool {
+	return IsStatus(err, http.StatusTooManyRequests)
+}
+
+// IsAuth reports if err is caused by invalid keys, signature or expired request
+func IsAuth(err error) bool {
+	return IsStatus(err, http.StatusUnauthorized) || IsStatus(err, http.StatusForbidden)
+}
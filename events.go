@@ -0,0 +1,160 @@
+package oo
+
+import (
+	"io"
+	"time"
+)
+
+// EventType is the kind of the upload progress event
+type EventType int
+
+const (
+	// AssetCreated is sent when the asset is created or prepared for replacement
+	AssetCreated EventType = iota
+	// ChunkStarted is sent when the chunk begins to upload
+	ChunkStarted
+	// ChunkProgress is sent when more bytes of the chunk are sent
+	ChunkProgress
+	// ChunkCompleted is sent when the chunk is accepted by the storage
+	ChunkCompleted
+	// ChunkRetried is sent when the chunk is sent again after the failed attempt
+	ChunkRetried
+	// ChunkFailed is sent when the chunk couldn't be uploaded, Err holds the reason
+	ChunkFailed
+	// UploadCompleted is sent when all the chunks are uploaded
+	UploadCompleted
+	// ProcessingTriggered is sent when the transcoding job is started
+	ProcessingTriggered
+)
+
+var eventTypeNames = map[EventType]string{
+	AssetCreated:        "asset_created",
+	ChunkStarted:        "chunk_started",
+	ChunkProgress:       "chunk_progress",
+	ChunkCompleted:      "chunk_completed",
+	ChunkRetried:        "chunk_retried",
+	ChunkFailed:         "chunk_failed",
+	UploadCompleted:     "upload_completed",
+	ProcessingTriggered: "processing_triggered",
+}
+
+// String returns the name of the event type like chunk_completed
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Event describes the progress of the upload
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	EmbedCode string
+	// Chunk is the index of the chunk, -1 for the events which are not about a chunk
+	Chunk int
+	// ChunkSize and ChunkSent are the size of the chunk and the bytes of it sent so far
+	ChunkSize int64
+	ChunkSent int64
+	// Attempt is the number of the attempt to upload the chunk
+	Attempt int
+	// Sent and Total are the bytes of the whole file, the chunks uploaded before the resume are counted as sent
+	Sent  int64
+	Total int64
+	// Throughput is the average speed in bytes per second since the upload started
+	Throughput float64
+	// ETA is the estimated time left, zero if the speed is still unknown
+	ETA time.Duration
+	Err error
+}
+
+// SetEventFunc sets a function receiving the progress events of the upload.
+// The events are delivered one at a time, so the function doesn't need locking,
+// but it should return quickly as it holds up the chunk uploads
+func (u *Uploader) SetEventFunc(f func(Event)) {
+	u.eventFunc = f
+}
+
+// emit sends the event which isn't about a chunk
+func (u *Uploader) emit(t EventType, embedCode string) {
+	if u.eventFunc == nil {
+		return
+	}
+	u.eventMu.Lock()
+	defer u.eventMu.Unlock()
+	u.eventFunc(Event{Type: t, Time: time.Now(), EmbedCode: embedCode, Chunk: -1})
+}
+
+// progress counts the bytes sent by all the chunks of the upload
+type progress struct {
+	u         *Uploader
+	embedCode string
+	start     time.Time
+	// resumed are the bytes uploaded before the resume, they don't count in the throughput
+	resumed int64
+	total   int64
+	sent    int64
+	chunks  map[int]int64
+}
+
+func (u *Uploader) newProgress(embedCode string, total int64) *progress {
+	return &progress{u: u, embedCode: embedCode, start: time.Now(), total: total, chunks: make(map[int]int64)}
+}
+
+// skip counts the chunk uploaded before the resume
+func (p *progress) skip(size int64) {
+	p.u.eventMu.Lock()
+	defer p.u.eventMu.Unlock()
+	p.resumed += size
+	p.sent += size
+}
+
+// chunk sends the event about the chunk. sent is the bytes of the chunk sent so far,
+// it goes back to zero when the chunk is retried
+func (p *progress) chunk(t EventType, index int, size, sent int64, attempt int, err error) {
+	if p.u.eventFunc == nil {
+		return
+	}
+	p.u.eventMu.Lock()
+	defer p.u.eventMu.Unlock()
+
+	p.sent += sent - p.chunks[index]
+	p.chunks[index] = sent
+
+	now := time.Now()
+	e := Event{
+		Type:      t,
+		Time:      now,
+		EmbedCode: p.embedCode,
+		Chunk:     index,
+		ChunkSize: size,
+		ChunkSent: sent,
+		Attempt:   attempt,
+		Sent:      p.sent,
+		Total:     p.total,
+		Err:       err,
+	}
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+		e.Throughput = float64(p.sent-p.resumed) / elapsed
+	}
+	if e.Throughput > 0 {
+		e.ETA = time.Duration(float64(p.total-p.sent) / e.Throughput * float64(time.Second))
+	}
+	p.u.eventFunc(e)
+}
+
+// progressBody reports the bytes of the chunk read by the http client
+type progressBody struct {
+	io.ReadCloser
+	sent   int64
+	report func(sent int64)
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.sent += int64(n)
+		b.report(b.sent)
+	}
+	return n, err
+}
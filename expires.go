@@ -0,0 +1,96 @@
+package oo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithExpiration sets how long the signed requests stay valid, it overrides the delta hours of NewClient.
+// Short durations are useful for the signed urls given to the third parties
+func WithExpiration(d time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.expiration = d
+	}
+}
+
+// WithExpiresAt makes all the signed requests expire at the given time
+func WithExpiresAt(t time.Time) Option {
+	return func(cfg *clientConfig) {
+		cfg.expiresAt = t
+	}
+}
+
+// WithSkewCorrection makes the Client compare the local clock with the Date header
+// of the API responses and shift the expires values of the next requests by the difference,
+// so the requests from the hosts with the wrong time aren't rejected as expired
+func WithSkewCorrection() Option {
+	return func(cfg *clientConfig) {
+		cfg.skew = true
+	}
+}
+
+// ClockSkew returns how far the server clock is ahead of the local one,
+// 0 unless the skew correction is enabled and a response with Date header is received
+func (c Client) ClockSkew() time.Duration {
+	return c.clock.offset()
+}
+
+// expires generates expires value based on the expiration options or Delta hours
+func (c Client) expires() string {
+	if !c.expiresAt.IsZero() {
+		return strconv.FormatInt(c.expiresAt.Unix(), 10)
+	}
+	d := c.expiration
+	if d == 0 {
+		d = time.Hour * time.Duration(c.Delta)
+	}
+	// Generate the expires value by adding the duration to the server time
+	timestamp := time.Now().Add(c.clock.offset()).Add(d).Unix()
+	return strconv.FormatInt(timestamp, 10)
+}
+
+// clockSkew is shared by all the copies of the Client
+// and keeps the difference between the server and the local clocks
+type clockSkew struct {
+	mu   sync.Mutex
+	skew time.Duration
+}
+
+// offset returns the skew, the nil clockSkew means the correction is disabled
+func (s *clockSkew) offset() time.Duration {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.skew
+}
+
+// update measures the skew by the Date header of the response to the request sent and received at the given times.
+// Date has the precision of a second, so the server time is taken in the middle of the second
+// and compared with the middle of the round trip
+func (s *clockSkew) update(h http.Header, sent, received time.Time, out io.Writer) {
+	if s == nil {
+		return
+	}
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := date.Add(time.Second / 2).Sub(local)
+
+	s.mu.Lock()
+	prev := s.skew
+	s.skew = skew
+	s.mu.Unlock()
+
+	// the measurements differ by the precision of the header, only the real changes are logged
+	if change := skew - prev; change >= time.Second || change <= -time.Second {
+		fmt.Fprintf(out, "CLOCK: the server clock differs from the local one by %v\n", skew.Round(time.Second))
+	}
+}
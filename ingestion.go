@@ -0,0 +1,42 @@
+package oo
+
+import (
+	"context"
+	"net/url"
+)
+
+// IngestionLog is a record about a file delivered to Ooyala ingestion
+type IngestionLog struct {
+	User         string `json:"user"`
+	CreationTime string `json:"creation_time"`
+	EmbedCode    string `json:"embed_code"`
+	ErrorMessage string `json:"error_message"`
+	FileType     string `json:"file_type"`
+	Status       string `json:"status"`
+	ID           string `json:"id"`
+	FileID       string `json:"file_id"`
+	FileName     string `json:"file_name"`
+}
+
+// GetIngestionLogs retreives the ingestion logs filtered by the values
+// like file_name or period=start=2018-10-10;end=2018-10-20 following all the pages
+func (c Client) GetIngestionLogs(values url.Values) ([]IngestionLog, error) {
+	return c.GetIngestionLogsContext(context.Background(), values)
+}
+
+// GetIngestionLogsContext is the same as GetIngestionLogs but with the given context
+func (c Client) GetIngestionLogsContext(ctx context.Context, values url.Values) ([]IngestionLog, error) {
+	var logs []IngestionLog
+	pager := c.Paginate(ctx, "/v2/ingestion/logs", values, PageOptions{})
+	for pager.Next() {
+		var l IngestionLog
+		if err := pager.Decode(&l); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
@@ -0,0 +1,324 @@
+package oo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Label is a node of the labels hierarchy of the Ooyala account
+type Label struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	ParentID string `json:"parent_id"`
+}
+
+// LabelNode is a label with its children in the labels tree
+type LabelNode struct {
+	Label
+	Children []*LabelNode
+}
+
+// LabelTree arranges the labels into the tree and returns its roots.
+// The nodes on every level are sorted by name
+func LabelTree(labels []Label) []*LabelNode {
+	nodes := make(map[string]*LabelNode, len(labels))
+	for _, l := range labels {
+		nodes[l.ID] = &LabelNode{Label: l}
+	}
+	var roots []*LabelNode
+	for _, l := range labels {
+		node := nodes[l.ID]
+		if parent, ok := nodes[l.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+			continue
+		}
+		roots = append(roots, node)
+	}
+	sortNodes(roots)
+	return roots
+}
+
+func sortNodes(nodes []*LabelNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+}
+
+// CleanLabelPath brings the full name of a label to the form /Parent/Child
+func CleanLabelPath(path string) string {
+	var parts []string
+	for _, p := range strings.Split(path, "/") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// GetLabels retreives all the labels of the Ooyala account
+func (c Client) GetLabels() ([]Label, error) {
+	return c.GetLabelsContext(context.Background())
+}
+
+// GetLabelsContext is the same as GetLabels but with the given context
+func (c Client) GetLabelsContext(ctx context.Context) ([]Label, error) {
+	var labels []Label
+	pager := c.Paginate(ctx, "/v2/labels", nil, PageOptions{})
+	for pager.Next() {
+		var l Label
+		if err := pager.Decode(&l); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// GetLabelByPath retreives the label by its full name like /Sports/Football
+func (c Client) GetLabelByPath(path string) (*Label, error) {
+	return c.GetLabelByPathContext(context.Background(), path)
+}
+
+// GetLabelByPathContext is the same as GetLabelByPath but with the given context
+func (c Client) GetLabelByPathContext(ctx context.Context, path string) (*Label, error) {
+	index, err := c.labelIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := index[CleanLabelPath(path)]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Message: "label " + path + " is not found"}
+	}
+	return &l, nil
+}
+
+// CreateLabel creates a label under the parent, empty parentID creates the root label
+func (c Client) CreateLabel(name, parentID string) (*Label, error) {
+	return c.CreateLabelContext(context.Background(), name, parentID)
+}
+
+// CreateLabelContext is the same as CreateLabel but with the given context
+func (c Client) CreateLabelContext(ctx context.Context, name, parentID string) (*Label, error) {
+	data := map[string]string{"name": name}
+	if parentID != "" {
+		data["parent_id"] = parentID
+	}
+	return c.writeLabel(ctx, http.MethodPost, "/v2/labels", data)
+}
+
+// CreateLabelPaths makes sure the labels with the given full names exist creating
+// the missing ones together with their parents. It returns the labels in the order of paths
+func (c Client) CreateLabelPaths(paths ...string) ([]Label, error) {
+	return c.CreateLabelPathsContext(context.Background(), paths...)
+}
+
+// CreateLabelPathsContext is the same as CreateLabelPaths but with the given context
+func (c Client) CreateLabelPathsContext(ctx context.Context, paths ...string) ([]Label, error) {
+	index, err := c.labelIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]Label, 0, len(paths))
+	for _, path := range paths {
+		l, err := c.ensureLabelPath(ctx, index, CleanLabelPath(path))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create label %v: %w", path, err)
+		}
+		labels = append(labels, l)
+	}
+	return labels, nil
+}
+
+// RenameLabelPath renames the last part of the label full name
+func (c Client) RenameLabelPath(path, name string) (*Label, error) {
+	return c.RenameLabelPathContext(context.Background(), path, name)
+}
+
+// RenameLabelPathContext is the same as RenameLabelPath but with the given context
+func (c Client) RenameLabelPathContext(ctx context.Context, path, name string) (*Label, error) {
+	l, err := c.GetLabelByPathContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return c.writeLabel(ctx, http.MethodPatch, "/v2/labels/"+l.ID, map[string]string{"name": name})
+}
+
+// DeleteLabelPath deletes the label by its full name
+func (c Client) DeleteLabelPath(path string) error {
+	return c.DeleteLabelPathContext(context.Background(), path)
+}
+
+// DeleteLabelPathContext is the same as DeleteLabelPath but with the given context
+func (c Client) DeleteLabelPathContext(ctx context.Context, path string) error {
+	l, err := c.GetLabelByPathContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	return c.DeleteLabelContext(ctx, l.ID)
+}
+
+// DeleteLabel deletes the label by id
+func (c Client) DeleteLabel(id string) error {
+	return c.DeleteLabelContext(context.Background(), id)
+}
+
+// DeleteLabelContext is the same as DeleteLabel but with the given context
+func (c Client) DeleteLabelContext(ctx context.Context, id string) error {
+	r, err := c.DeleteContext(ctx, "/v2/labels/"+id)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return checkServiceError(r, http.StatusOK)
+}
+
+// GetAssetLabels retreives the labels attached to the asset
+func (c Client) GetAssetLabels(ec string) ([]Label, error) {
+	return c.GetAssetLabelsContext(context.Background(), ec)
+}
+
+// GetAssetLabelsContext is the same as GetAssetLabels but with the given context
+func (c Client) GetAssetLabelsContext(ctx context.Context, ec string) ([]Label, error) {
+	var labels []Label
+	pager := c.Paginate(ctx, "/v2/assets/"+ec+"/labels", nil, PageOptions{})
+	for pager.Next() {
+		var l Label
+		if err := pager.Decode(&l); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// AddAssetLabels attaches the labels with the given ids to the asset
+func (c Client) AddAssetLabels(ec string, ids ...string) error {
+	return c.AddAssetLabelsContext(context.Background(), ec, ids...)
+}
+
+// AddAssetLabelsContext is the same as AddAssetLabels but with the given context
+func (c Client) AddAssetLabelsContext(ctx context.Context, ec string, ids ...string) error {
+	body, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	r, err := c.PostContext(ctx, "/v2/assets/"+ec+"/labels", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	return checkServiceError(r, http.StatusOK)
+}
+
+// AddAssetLabelPaths attaches the labels with the given full names to the asset
+// creating the missing labels first
+func (c Client) AddAssetLabelPaths(ec string, paths ...string) error {
+	return c.AddAssetLabelPathsContext(context.Background(), ec, paths...)
+}
+
+// AddAssetLabelPathsContext is the same as AddAssetLabelPaths but with the given context
+func (c Client) AddAssetLabelPathsContext(ctx context.Context, ec string, paths ...string) error {
+	labels, err := c.CreateLabelPathsContext(ctx, paths...)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(labels))
+	for _, l := range labels {
+		ids = append(ids, l.ID)
+	}
+	return c.AddAssetLabelsContext(ctx, ec, ids...)
+}
+
+// RemoveAssetLabel detaches the label with the given id from the asset
+func (c Client) RemoveAssetLabel(ec, id string) error {
+	return c.RemoveAssetLabelContext(context.Background(), ec, id)
+}
+
+// RemoveAssetLabelContext is the same as RemoveAssetLabel but with the given context
+func (c Client) RemoveAssetLabelContext(ctx context.Context, ec, id string) error {
+	r, err := c.DeleteContext(ctx, "/v2/assets/"+ec+"/labels/"+id)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return checkServiceError(r, http.StatusOK)
+}
+
+// labelIndex returns all the labels of the account by their full names
+func (c Client) labelIndex(ctx context.Context) (map[string]Label, error) {
+	labels, err := c.GetLabelsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]Label, len(labels))
+	for _, l := range labels {
+		index[CleanLabelPath(l.FullName)] = l
+	}
+	return index, nil
+}
+
+// ensureLabelPath creates the label and its parents which are absent in the index
+func (c Client) ensureLabelPath(ctx context.Context, index map[string]Label, path string) (Label, error) {
+	if l, ok := index[path]; ok {
+		return l, nil
+	}
+	if path == "/" {
+		return Label{}, fmt.Errorf("empty label name")
+	}
+	cut := strings.LastIndex(path, "/")
+	var parent Label
+	if cut > 0 {
+		var err error
+		if parent, err = c.ensureLabelPath(ctx, index, path[:cut]); err != nil {
+			return Label{}, err
+		}
+	}
+	l, err := c.CreateLabelContext(ctx, path[cut+1:], parent.ID)
+	if err != nil {
+		return Label{}, err
+	}
+	if l.FullName == "" {
+		l.FullName = path
+	}
+	index[path] = *l
+	return *l, nil
+}
+
+func (c Client) writeLabel(ctx context.Context, method, path string, data map[string]string) (*Label, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.sendRequest(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var l Label
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
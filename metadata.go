@@ -0,0 +1,96 @@
+package oo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// CustomMetadata is the custom metadata of an asset
+type CustomMetadata map[string]string
+
+// GetMetadata retreives the custom metadata of the asset by the given embed code
+func (c Client) GetMetadata(ec string) (CustomMetadata, error) {
+	return c.GetMetadataContext(context.Background(), ec)
+}
+
+// GetMetadataContext is the same as GetMetadata but with the given context
+func (c Client) GetMetadataContext(ctx context.Context, ec string) (CustomMetadata, error) {
+	r, err := c.GetContext(ctx, "/v2/assets/"+ec+"/metadata")
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return decodeMetadata(r.Body)
+}
+
+// SetMetadata replaces all the custom metadata of the asset with the given one
+func (c Client) SetMetadata(ec string, m CustomMetadata) (CustomMetadata, error) {
+	return c.SetMetadataContext(context.Background(), ec, m)
+}
+
+// SetMetadataContext is the same as SetMetadata but with the given context
+func (c Client) SetMetadataContext(ctx context.Context, ec string, m CustomMetadata) (CustomMetadata, error) {
+	return c.writeMetadata(ctx, http.MethodPut, ec, m)
+}
+
+// PatchMetadata merges the given keys into the custom metadata of the asset
+func (c Client) PatchMetadata(ec string, m CustomMetadata) (CustomMetadata, error) {
+	return c.PatchMetadataContext(context.Background(), ec, m)
+}
+
+// PatchMetadataContext is the same as PatchMetadata but with the given context
+func (c Client) PatchMetadataContext(ctx context.Context, ec string, m CustomMetadata) (CustomMetadata, error) {
+	return c.writeMetadata(ctx, http.MethodPatch, ec, m)
+}
+
+// DeleteMetadataKey removes the key from the custom metadata of the asset
+func (c Client) DeleteMetadataKey(ec, key string) error {
+	return c.DeleteMetadataKeyContext(context.Background(), ec, key)
+}
+
+// DeleteMetadataKeyContext is the same as DeleteMetadataKey but with the given context
+func (c Client) DeleteMetadataKeyContext(ctx context.Context, ec, key string) error {
+	r, err := c.DeleteContext(ctx, "/v2/assets/"+ec+"/metadata/"+url.PathEscape(key))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return checkServiceError(r, http.StatusOK)
+}
+
+func (c Client) writeMetadata(ctx context.Context, method, ec string, m CustomMetadata) (CustomMetadata, error) {
+	if m == nil {
+		m = CustomMetadata{}
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.sendRequest(ctx, method, "/v2/assets/"+ec+"/metadata", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err := checkServiceError(r, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return decodeMetadata(r.Body)
+}
+
+func decodeMetadata(r io.Reader) (CustomMetadata, error) {
+	m := CustomMetadata{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
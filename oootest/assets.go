@@ -0,0 +1,414 @@
+package oootest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimdiden/oo"
+)
+
+// route dispatches the signed API request
+func (s *Server) route(w http.ResponseWriter, r *http.Request, body []byte) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 5 && parts[0] == "v2" && parts[1] == "discover" && parts[2] == "similar" && parts[3] == "assets":
+		s.discover(w, r, parts[4])
+		return
+	case len(parts) < 2 || parts[0] != "v2" || parts[1] != "assets":
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.URL.Path)
+		return
+	case len(parts) == 2:
+		switch r.Method {
+		case http.MethodGet:
+			s.listAssets(w, r)
+		case http.MethodPost:
+			s.createAsset(w, body)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assets[parts[2]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "asset "+parts[2]+" is not found")
+		return
+	}
+	rest := strings.Join(parts[3:], "/")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, a.view())
+	case rest == "" && (r.Method == http.MethodPatch || r.Method == http.MethodPut):
+		s.updateAsset(w, a, body)
+	case rest == "" && r.Method == http.MethodDelete:
+		delete(s.assets, a.EmbedCode)
+		w.WriteHeader(http.StatusNoContent)
+	case rest == "replacement" && r.Method == http.MethodPost:
+		s.replaceAsset(w, a, body)
+	case (rest == "uploading_urls" || rest == "replacement/uploading_urls") && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.uploadingURLs(a))
+	case (rest == "upload_status" || rest == "replacement/upload_status") && r.Method == http.MethodPut:
+		s.uploadStatus(w, a, body)
+	case rest == "processing_profile" && r.Method == http.MethodPost:
+		var data struct {
+			ID string `json:"processing_profile_id"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		a.processingProfile = data.ID
+		writeJSON(w, http.StatusOK, data)
+	case rest == "preview_image_files" && r.Method == http.MethodPost:
+		a.previewImage = body
+		writeJSON(w, http.StatusOK, map[string]string{"status": "uploaded"})
+	case rest == "source_file_info" && r.Method == http.MethodGet:
+		content := a.content()
+		info := map[string]interface{}{
+			"original_file_name": a.FileName,
+			"file_size":          len(content),
+		}
+		if !s.noMD5 {
+			sum := md5.Sum(content)
+			info["md5"] = hex.EncodeToString(sum[:])
+		}
+		writeJSON(w, http.StatusOK, info)
+	case rest == "metadata":
+		s.metadata(w, r, a, body)
+	case strings.HasPrefix(rest, "metadata/") && r.Method == http.MethodDelete:
+		key, _ := url.PathUnescape(strings.TrimPrefix(rest, "metadata/"))
+		delete(a.metadata, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.URL.Path)
+	}
+}
+
+func (s *Server) createAsset(w http.ResponseWriter, body []byte) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fileSize, err1 := intValue(data["file_size"])
+	chunkSize, err2 := intValue(data["chunk_size"])
+	if err1 != nil || err2 != nil || chunkSize <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid file_size or chunk_size")
+		return
+	}
+	assetType, _ := data["asset_type"].(string)
+	name, _ := data["name"].(string)
+	fileName, _ := data["file_name"].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &asset{
+		Asset: oo.Asset{
+			EmbedCode: s.newEmbedCode(),
+			Name:      name,
+			FileName:  fileName,
+			AssetType: oo.AssetType(assetType),
+			Status:    oo.StatusUploading,
+			CreatedAt: oo.Time{Time: time.Now().UTC().Truncate(time.Second)},
+		},
+		fileSize:  fileSize,
+		chunkSize: chunkSize,
+		chunks:    make(map[int][]byte),
+	}
+	a.UpdatedAt = a.CreatedAt
+	s.assets[a.EmbedCode] = a
+	writeJSON(w, http.StatusOK, a.view())
+}
+
+func (s *Server) replaceAsset(w http.ResponseWriter, a *asset, body []byte) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fileSize, err1 := intValue(data["file_size"])
+	chunkSize, err2 := intValue(data["chunk_size"])
+	if err1 != nil || err2 != nil || chunkSize <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid file_size or chunk_size")
+		return
+	}
+	a.fileSize = fileSize
+	a.chunkSize = chunkSize
+	a.chunks = make(map[int][]byte)
+	a.replacing = true
+	writeJSON(w, http.StatusOK, a.view())
+}
+
+// updateAsset applies the fields of the body over the stored asset
+func (s *Server) updateAsset(w http.ResponseWriter, a *asset, body []byte) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	current, err := json.Marshal(a.Asset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var fields map[string]json.RawMessage
+	json.Unmarshal(current, &fields)
+	for k, v := range patch {
+		if k == "embed_code" {
+			continue
+		}
+		fields[k] = v
+	}
+	merged, _ := json.Marshal(fields)
+	var updated oo.Asset
+	if err := json.Unmarshal(merged, &updated); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	updated.UpdatedAt = oo.Time{Time: time.Now().UTC().Truncate(time.Second)}
+	a.Asset = updated
+	writeJSON(w, http.StatusOK, a.view())
+}
+
+func (s *Server) uploadingURLs(a *asset) []string {
+	var urls []string
+	for i, offset := 0, int64(0); offset < a.fileSize; i, offset = i+1, offset+a.chunkSize {
+		size := a.chunkSize
+		if offset+size > a.fileSize {
+			size = a.fileSize - offset
+		}
+		urls = append(urls, fmt.Sprintf("%v%v%v/%v?filesize=%v", s.URL, uploadPrefix, a.EmbedCode, i, size))
+	}
+	return urls
+}
+
+// uploadChunk stores the chunk sent to the uploading url
+func (s *Server) uploadChunk(w http.ResponseWriter, r *http.Request, body []byte) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, uploadPrefix), "/")
+	if r.Method != http.MethodPut || len(parts) != 2 {
+		writeError(w, http.StatusNotFound, "unknown uploading url")
+		return
+	}
+	index, err := strconv.Atoi(parts[1])
+	size, err2 := strconv.ParseInt(r.URL.Query().Get("filesize"), 10, 64)
+	if err != nil || err2 != nil {
+		writeError(w, http.StatusNotFound, "unknown uploading url")
+		return
+	}
+	if int64(len(body)) != size {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("chunk size %v, expected %v", len(body), size))
+		return
+	}
+	if sum := r.Header.Get("Content-MD5"); sum != "" {
+		h := md5.Sum(body)
+		if sum != base64.StdEncoding.EncodeToString(h[:]) {
+			writeError(w, http.StatusBadRequest, "Content-MD5 mismatch")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assets[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "asset "+parts[0]+" is not found")
+		return
+	}
+	a.chunks[index] = body
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadStatus starts the processing once all the chunks are uploaded
+func (s *Server) uploadStatus(w http.ResponseWriter, a *asset, body []byte) {
+	var data struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil || data.Status != "uploaded" {
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	if int64(len(a.content())) != a.fileSize || len(a.chunks) != len(s.uploadingURLs(a)) {
+		writeError(w, http.StatusBadRequest, "the file is not uploaded completely")
+		return
+	}
+	a.Status = s.processed
+	a.replacing = false
+	writeJSON(w, http.StatusOK, data)
+}
+
+func (s *Server) metadata(w http.ResponseWriter, r *http.Request, a *asset, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPatch:
+		var m oo.CustomMetadata
+		if err := json.Unmarshal(body, &m); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if r.Method == http.MethodPut || a.metadata == nil {
+			a.metadata = oo.CustomMetadata{}
+		}
+		for k, v := range m {
+			a.metadata[k] = v
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	m := a.metadata
+	if m == nil {
+		m = oo.CustomMetadata{}
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) discover(w http.ResponseWriter, r *http.Request, ec string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	similars := s.similars[ec]
+	if similars == nil {
+		similars = []oo.Asset{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": similars})
+}
+
+// listAssets supports where with the conditions like field='value' joined by AND,
+// limit as the page size and the pages linked by next_page
+func (s *Server) listAssets(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	conds, err := parseWhere(q.Get("where"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	offset, _ := strconv.Atoi(q.Get("page_token"))
+
+	s.mu.Lock()
+	var items []oo.Asset
+	for _, a := range s.assets {
+		if a.matches(conds) {
+			items = append(items, a.view())
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].EmbedCode < items[j].EmbedCode })
+
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	page := map[string]interface{}{}
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+		next := url.Values{}
+		for k, v := range q {
+			next[k] = v
+		}
+		next.Set("page_token", strconv.Itoa(offset+limit))
+		page["next_page"] = "/v2/assets?" + next.Encode()
+	}
+	if items == nil {
+		items = []oo.Asset{}
+	}
+	page["items"] = items
+	writeJSON(w, http.StatusOK, page)
+}
+
+// cond is the condition field='value' of where parameter
+type cond struct {
+	field string
+	value string
+}
+
+func parseWhere(where string) ([]cond, error) {
+	if where == "" {
+		return nil, nil
+	}
+	var conds []cond
+	for _, part := range strings.Split(where, " AND ") {
+		part = strings.TrimSpace(part)
+		eq := strings.Index(part, "=")
+		if eq <= 0 || strings.ContainsAny(part[:eq], "<>!() ") {
+			return nil, fmt.Errorf("unsupported condition %q", part)
+		}
+		value := part[eq+1:]
+		if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+			return nil, fmt.Errorf("unsupported value in %q", part)
+		}
+		value = strings.Replace(value[1:len(value)-1], `\'`, `'`, -1)
+		value = strings.Replace(value, `\\`, `\`, -1)
+		conds = append(conds, cond{field: part[:eq], value: value})
+	}
+	return conds, nil
+}
+
+func (a *asset) matches(conds []cond) bool {
+	for _, c := range conds {
+		if a.field(c.field) != c.value {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *asset) field(name string) string {
+	if strings.HasPrefix(name, "metadata.") {
+		return a.metadata[strings.TrimPrefix(name, "metadata.")]
+	}
+	switch name {
+	case "embed_code":
+		return a.EmbedCode
+	case "name":
+		return a.Name
+	case "status":
+		return a.Status
+	case "asset_type":
+		return string(a.AssetType)
+	case "original_file_name":
+		return a.FileName
+	case "external_id":
+		return a.ExternalID
+	}
+	return ""
+}
+
+// view returns the copy of the asset as it is sent in the responses
+func (a *asset) view() oo.Asset {
+	v := a.Asset
+	v.Metadata = nil
+	v.Labels = nil
+	return v
+}
+
+// content joins the uploaded chunks in order
+func (a *asset) content() []byte {
+	var b bytes.Buffer
+	for i := 0; i < len(a.chunks); i++ {
+		b.Write(a.chunks[i])
+	}
+	return b.Bytes()
+}
+
+// intValue reads the number sent either as JSON number or string
+func intValue(v interface{}) (int64, error) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), nil
+	case string:
+		return strconv.ParseInt(n, 10, 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
@@ -0,0 +1,318 @@
+// Package oootest provides a fake Backlot API server for testing the code built on oo offline.
+// The server checks the signatures of the requests, keeps the assets in memory
+// and can inject errors, latency and rate limit headers.
+package oootest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimdiden/oo"
+)
+
+// uploadPrefix is the path of the chunk uploading urls.
+// They are presigned by Backlot, so the requests to them aren't checked
+const uploadPrefix = "/oootest/upload/"
+
+// Request is a request received by the Server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// fault is an error injected into the responses
+type fault struct {
+	method string
+	path   string
+	status int
+	times  int
+}
+
+// Server is a fake Backlot API server
+type Server struct {
+	*httptest.Server
+	APIKey string
+	Secret string
+
+	mu        sync.Mutex
+	nextID    int
+	assets    map[string]*asset
+	similars  map[string][]oo.Asset
+	requests  []Request
+	faults    []*fault
+	latency   time.Duration
+	processed string
+	// noMD5 hides md5 in the source file info
+	noMD5 bool
+	// credits are sent in X-RateLimit-Credits if limited
+	limited bool
+	credits int
+	reset   time.Time
+	window  time.Duration
+	refill  int
+}
+
+// asset is the stored asset with the uploaded content
+type asset struct {
+	oo.Asset
+	metadata          oo.CustomMetadata
+	chunkSize         int64
+	fileSize          int64
+	chunks            map[int][]byte
+	replacing         bool
+	processingProfile string
+	previewImage      []byte
+}
+
+// NewServer starts the server accepting the requests signed with the given keys.
+// The server should be closed when it is not needed anymore
+func NewServer(apiKey, secret string) *Server {
+	s := &Server{
+		APIKey:    apiKey,
+		Secret:    secret,
+		assets:    make(map[string]*asset),
+		similars:  make(map[string][]oo.Asset),
+		processed: oo.StatusLive,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns the oo.Client sending the requests to the server
+func (s *Server) Client(opts ...oo.Option) *oo.Client {
+	c, err := oo.NewClient(s.Secret, s.APIKey, s.URL, 1, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Requests returns the requests received by the server so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Fail makes the server respond with the status to the next requests.
+// The request matches if its method is the same, or method is empty,
+// and its path starts with the given one. times is the number of the failed responses
+func (s *Server) Fail(method, path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{method: method, path: path, status: status, times: times})
+}
+
+// SetLatency delays every response
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetRateLimit makes the server send X-RateLimit-Credits and X-RateLimit-Reset headers.
+// Every request costs a credit, the credits are restored every window.
+// The server responds with 429 when the credits run out
+func (s *Server) SetRateLimit(credits int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limited = true
+	s.credits = credits
+	s.refill = credits
+	s.window = window
+	s.reset = time.Now().Add(window)
+}
+
+// SetProcessedStatus sets the status the asset gets when its processing is triggered, live by default
+func (s *Server) SetProcessedStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed = status
+}
+
+// SetReportMD5 sets if md5 of the uploaded file is reported in the source file info, true by default
+func (s *Server) SetReportMD5(report bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noMD5 = !report
+}
+
+// AddAsset stores the asset, the embed code is generated if it is empty
+func (s *Server) AddAsset(a oo.Asset) oo.Asset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a.EmbedCode == "" {
+		a.EmbedCode = s.newEmbedCode()
+	}
+	s.assets[a.EmbedCode] = &asset{Asset: a, metadata: a.Metadata, chunks: make(map[int][]byte)}
+	return a
+}
+
+// Asset returns the stored asset
+func (s *Server) Asset(ec string) (oo.Asset, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assets[ec]
+	if !ok {
+		return oo.Asset{}, false
+	}
+	return a.view(), true
+}
+
+// Content returns the file uploaded for the asset
+func (s *Server) Content(ec string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.assets[ec]
+	if !ok {
+		return nil
+	}
+	return a.content()
+}
+
+// PreviewImage returns the thumbnail uploaded for the asset
+func (s *Server) PreviewImage(ec string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.assets[ec]; ok {
+		return a.previewImage
+	}
+	return nil
+}
+
+// ProcessingProfile returns the processing profile set for the asset
+func (s *Server) ProcessingProfile(ec string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.assets[ec]; ok {
+		return a.processingProfile
+	}
+	return ""
+}
+
+// SetSimilars sets the recommendations returned by the discover endpoint for the asset
+func (s *Server) SetSimilars(ec string, similars []oo.Asset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.similars[ec] = similars
+}
+
+func (s *Server) newEmbedCode() string {
+	s.nextID++
+	return fmt.Sprintf("oootest%08d", s.nextID)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header.Clone(), Body: body})
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if !s.rateLimit(w) {
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if status := s.fault(r); status != 0 {
+		writeError(w, status, "injected error")
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, uploadPrefix) {
+		s.uploadChunk(w, r, body)
+		return
+	}
+	if err := s.checkSignature(r); err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	s.route(w, r, body)
+}
+
+// rateLimit spends a credit and sets the headers, it returns false if the credits run out
+func (s *Server) rateLimit(w http.ResponseWriter) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.limited {
+		return true
+	}
+	now := time.Now()
+	if !now.Before(s.reset) {
+		s.credits = s.refill
+		s.reset = now.Add(s.window)
+	}
+	ok := s.credits > 0
+	if ok {
+		s.credits--
+	}
+	w.Header().Set("X-RateLimit-Credits", strconv.Itoa(s.credits))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(s.reset.Sub(now).Seconds()+0.5)))
+	return ok
+}
+
+// fault returns the injected status for the request or 0
+func (s *Server) fault(r *http.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if (f.method == "" || f.method == r.Method) && strings.HasPrefix(r.URL.Path, f.path) {
+			if f.times--; f.times <= 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+			return f.status
+		}
+	}
+	return 0
+}
+
+// checkSignature verifies the signature of the request with the same algorithm as the client
+func (s *Server) checkSignature(r *http.Request) error {
+	if r.URL.Query().Get("api_key") != s.APIKey {
+		return fmt.Errorf("invalid api_key")
+	}
+	check, err := oo.VerifySignature(r, s.Secret)
+	if err != nil {
+		return err
+	}
+	if check.Expired {
+		return fmt.Errorf("the request has expired")
+	}
+	if !check.Valid {
+		return fmt.Errorf("invalid signature, expected for %q", check.Canonical)
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
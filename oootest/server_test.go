@@ -0,0 +1,402 @@
+package oootest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimdiden/oo"
+	"github.com/dimdiden/oo/oootest"
+)
+
+// fastRetry repeats the requests failed with the given statuses without long pauses
+func fastRetry(statuses ...int) oo.Option {
+	p := oo.RetryPolicy{MaxAttempts: 5, MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond, RetryStatus: map[int]bool{}}
+	for _, s := range statuses {
+		p.RetryStatus[s] = true
+	}
+	return oo.WithRetryPolicy(p)
+}
+
+func countRequests(srv *oootest.Server, method, path string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		if r.Method == method && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSignatureRejected(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+
+	if _, err := srv.Client().GetAsset(a.EmbedCode); err != nil {
+		t.Fatalf("the signed request is rejected: %v", err)
+	}
+	clients := map[string]*oo.Client{
+		"wrong secret":  mustClient(t, "wrong", "apikey", srv.URL),
+		"wrong api key": mustClient(t, "secret", "other", srv.URL),
+		"expired":       srv.Client(oo.WithExpiresAt(time.Now().Add(-time.Minute))),
+	}
+	for name, c := range clients {
+		_, err := c.GetAsset(a.EmbedCode)
+		var apiErr *oo.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%v: got %v, want 401", name, err)
+		}
+	}
+}
+
+func mustClient(t *testing.T, secret, apiKey, root string) *oo.Client {
+	t.Helper()
+	c, err := oo.NewClient(secret, apiKey, root, 1, oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	srv.SetRateLimit(2, 100*time.Millisecond)
+
+	// throttling is off, so the client runs out of credits and retries on 429
+	// until the credits are restored
+	c := srv.Client(oo.WithRateLimit(0, 0), oo.WithRetryPolicy(oo.RetryPolicy{
+		MaxAttempts: 5,
+		MinBackoff:  50 * time.Millisecond,
+		MaxBackoff:  100 * time.Millisecond,
+		RetryStatus: map[int]bool{http.StatusTooManyRequests: true},
+	}))
+	for i := 0; i < 4; i++ {
+		if _, err := c.GetAsset(a.EmbedCode); err != nil {
+			t.Fatalf("request %v: %v", i, err)
+		}
+	}
+	if n := countRequests(srv, "GET", "/v2/assets/"+a.EmbedCode); n <= 4 {
+		t.Errorf("%v requests are sent, want the retries of the limited ones", n)
+	}
+	if rl := c.RateLimit(); !rl.Known {
+		t.Error("the rate limit headers aren't tracked")
+	}
+}
+
+func TestPagerNextPage(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	for i := 0; i < 5; i++ {
+		srv.AddAsset(oo.Asset{Name: "paged"})
+	}
+	srv.AddAsset(oo.Asset{Name: "other"})
+
+	values := url.Values{"where": {"name='paged'"}}
+	p := srv.Client().Paginate(context.Background(), "/v2/assets", values, oo.PageOptions{PageSize: 2})
+	n := 0
+	for p.Next() {
+		var a oo.Asset
+		if err := p.Decode(&a); err != nil {
+			t.Fatal(err)
+		}
+		if a.Name != "paged" {
+			t.Errorf("asset %v doesn't match the query", a.Name)
+		}
+		n++
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("%v assets are listed, want 5", n)
+	}
+	if pages := countRequests(srv, "GET", "/v2/assets"); pages != 3 {
+		t.Errorf("%v pages are requested, want 3", pages)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	path := "/v2/assets/" + a.EmbedCode
+
+	srv.Fail("GET", path, http.StatusServiceUnavailable, 2)
+	if _, err := srv.Client(fastRetry(http.StatusServiceUnavailable)).GetAsset(a.EmbedCode); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRequests(srv, "GET", path); n != 3 {
+		t.Errorf("%v requests are sent, want 2 failed and 1 successful", n)
+	}
+
+	srv.Fail("", path, http.StatusInternalServerError, 1)
+	_, err := srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 1})).GetAsset(a.EmbedCode)
+	var apiErr *oo.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %v, want the injected 500", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	srv.SetLatency(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := srv.Client().GetAssetContext(ctx, a.EmbedCode); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the deadline exceeded", err)
+	}
+	start := time.Now()
+	if _, err := srv.Client().GetAsset(a.EmbedCode); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("the response took %v, want the latency", d)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	content := bytes.Repeat([]byte("dedupe"), 1000)
+	source := func() *oo.Source {
+		return oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4")
+	}
+	upload := func(opts oo.DedupeOptions) (*oo.Asset, error) {
+		u := oo.NewUploader(srv.Client())
+		u.SetDedupe(opts)
+		return u.CreateUploadSource(source(), "video", 2048)
+	}
+
+	first, err := upload(oo.DedupeOptions{Policy: oo.DedupeCreate, Match: oo.MatchContentHash | oo.MatchExternalID, ExternalID: "ext-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = upload(oo.DedupeOptions{Policy: oo.DedupeSkip, Match: oo.MatchExternalID, ExternalID: "ext-1"})
+	var dup *oo.DuplicateError
+	if !errors.As(err, &dup) || dup.Existing.EmbedCode != first.EmbedCode {
+		t.Fatalf("got %v, want the duplicate of %v", err, first.EmbedCode)
+	}
+	for _, r := range srv.Requests() {
+		if r.Method == "GET" && r.Path == "/v2/assets" && r.Query.Get("limit") != "1" {
+			t.Errorf("the lookup requests %q assets per page, want 1", r.Query.Get("limit"))
+		}
+	}
+	replaced, err := upload(oo.DedupeOptions{Policy: oo.DedupeReplace, Match: oo.MatchContentHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.EmbedCode != first.EmbedCode {
+		t.Errorf("asset %v is created instead of replacing %v", replaced.EmbedCode, first.EmbedCode)
+	}
+	if !bytes.Equal(srv.Content(first.EmbedCode), content) {
+		t.Error("the replaced content differs from the source")
+	}
+}
+
+func TestChunkedUploadResume(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "oootest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+	content := bytes.Repeat([]byte("chunk"), 1000)
+	source := func() *oo.Source {
+		return oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4")
+	}
+
+	// the last of 3 chunks fails and the upload stops
+	srv.Fail("PUT", "/oootest/upload/oootest00000001/2", http.StatusInternalServerError, 1)
+	u := oo.NewUploader(srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 1})))
+	u.SetConcurrency(1)
+	u.SetStatePath(statePath)
+	if _, err := u.CreateUploadSource(source(), "video", 2048); err == nil {
+		t.Fatal("the upload with the failed chunk succeeded")
+	}
+
+	u = oo.NewUploader(srv.Client())
+	u.SetStatePath(statePath)
+	asset, err := u.CreateUploadSource(source(), "video", 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.EmbedCode != "oootest00000001" {
+		t.Errorf("asset %v is created instead of resuming the upload", asset.EmbedCode)
+	}
+	if !bytes.Equal(srv.Content(asset.EmbedCode), content) {
+		t.Error("the uploaded content differs from the source")
+	}
+	for i, want := range []int{1, 1, 2} {
+		path := fmt.Sprintf("/oootest/upload/%v/%v", asset.EmbedCode, i)
+		if n := countRequests(srv, "PUT", path); n != want {
+			t.Errorf("chunk %v is sent %v times, want %v", i, n, want)
+		}
+	}
+}
+
+// TestDedupeHashOfClosedFile stores the content hash after the uploads
+// which don't look for the duplicates and close the file before it is stored
+func TestDedupeHashOfClosedFile(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "oootest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := bytes.Repeat([]byte("hashed"), 1000)
+	sum := sha256.Sum256(content)
+	video := filepath.Join(dir, "video.mp4")
+	if err := ioutil.WriteFile(video, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	open := func() *os.File {
+		f, err := os.Open(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	checkHash := func(name, ec string) {
+		t.Helper()
+		m, err := srv.Client().GetMetadata(ec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m[oo.DefaultHashKey] != hex.EncodeToString(sum[:]) {
+			t.Errorf("%v: content hash is %q", name, m[oo.DefaultHashKey])
+		}
+	}
+	dedupe := oo.DedupeOptions{Policy: oo.DedupeSkip, Match: oo.MatchContentHash}
+
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	u := oo.NewUploader(srv.Client())
+	u.SetDedupe(dedupe)
+	if _, err := u.ReplaceUploadAsset(open(), 2048, a.EmbedCode); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	checkHash("replace", a.EmbedCode)
+
+	// the interrupted upload is resumed without the lookup
+	statePath := filepath.Join(dir, "state.json")
+	ec := "oootest00000002"
+	srv.Fail("PUT", "/oootest/upload/"+ec+"/1", http.StatusInternalServerError, 1)
+	u = oo.NewUploader(srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 1})))
+	u.SetConcurrency(1)
+	u.SetStatePath(statePath)
+	if _, err := u.CreateUploadAsset(open(), "other", 2048); err == nil {
+		t.Fatal("the upload with the failed chunk succeeded")
+	}
+	u = oo.NewUploader(srv.Client())
+	u.SetDedupe(dedupe)
+	u.SetStatePath(statePath)
+	asset, err := u.CreateUploadAsset(open(), "other", 2048)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if asset.EmbedCode != ec {
+		t.Fatalf("asset %v is created instead of resuming %v", asset.EmbedCode, ec)
+	}
+	checkHash("resume", ec)
+}
+
+// onlyReader hides the other methods of the reader like Seek
+type onlyReader struct {
+	io.Reader
+}
+
+// TestSignedBodies sends the bodies which are signed while read in different ways,
+// the server checks the signatures and keeps the bodies
+func TestSignedBodies(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	// the stream bigger than 1MB is spooled to a temporary file
+	content := bytes.Repeat([]byte("0123456789"), 110000)
+
+	f, err := ioutil.TempFile("", "oootest-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(10, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := []struct {
+		name string
+		body io.Reader
+		want []byte
+	}{
+		{"seekable", f, content[10:]},
+		{"stream in memory", onlyReader{bytes.NewReader(content[:100])}, content[:100]},
+		{"stream spooled", onlyReader{bytes.NewReader(content)}, content},
+	}
+	u := oo.NewUploader(srv.Client())
+	for _, b := range bodies {
+		if err := u.UploadImageReader(b.body, a.EmbedCode); err != nil {
+			t.Fatalf("%v: %v", b.name, err)
+		}
+		if got := srv.PreviewImage(a.EmbedCode); !bytes.Equal(got, b.want) {
+			t.Errorf("%v: body of %v bytes is received, want %v", b.name, len(got), len(b.want))
+		}
+	}
+}
+
+// plainClient implements only the calls of ClientInterface without the context
+type plainClient struct {
+	c *oo.Client
+}
+
+func (p plainClient) Get(path string) (*http.Response, error) { return p.c.Get(path) }
+func (p plainClient) Post(path string, body io.Reader) (*http.Response, error) {
+	return p.c.Post(path, body)
+}
+func (p plainClient) Put(path string, body io.Reader) (*http.Response, error) {
+	return p.c.Put(path, body)
+}
+func (p plainClient) Patch(path string, body io.Reader) (*http.Response, error) {
+	return p.c.Patch(path, body)
+}
+func (p plainClient) Delete(path string) (*http.Response, error) { return p.c.Delete(path) }
+
+func TestSimilarsWithPlainClient(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	a := srv.AddAsset(oo.Asset{Name: "video"})
+	b := srv.AddAsset(oo.Asset{Name: "similar"})
+	srv.SetSimilars(a.EmbedCode, []oo.Asset{b})
+
+	for name, ci := range map[string]oo.ClientInterface{"plain": plainClient{srv.Client()}, "client": srv.Client()} {
+		similars, err := oo.GetNewSimilars(ci, a.EmbedCode, url.Values{})
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if len(similars.Assets) != 1 || similars.Assets[0].EmbedCode != b.EmbedCode {
+			t.Errorf("%v: similars are %+v, want %v", name, similars.Assets, b.EmbedCode)
+		}
+	}
+}
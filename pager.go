@@ -0,0 +1,136 @@
+package oo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PageOptions controls how the list endpoints are paginated
+type PageOptions struct {
+	// PageSize is the number of items requested per page, 0 keeps the API default
+	PageSize int
+	// Limit stops the iteration after the given number of items, 0 means all items
+	Limit int
+}
+
+// Pager iterates lazily over the items of a paginated list endpoint.
+// The next page is requested only when the items of the current one are consumed.
+// It follows next_page and next_page_url links signing every page request again.
+type Pager struct {
+	ctx    context.Context
+	client Client
+	next   string
+	opts   PageOptions
+	items  []json.RawMessage
+	item   json.RawMessage
+	count  int
+	err    error
+}
+
+// page is the common envelope of Ooyala list responses
+type page struct {
+	Items       []json.RawMessage `json:"items"`
+	Results     []json.RawMessage `json:"results"`
+	NextPage    string            `json:"next_page"`
+	NextPageURL string            `json:"next_page_url"`
+}
+
+// Paginate returns the Pager for the list endpoint by the given path and query values
+func (c Client) Paginate(ctx context.Context, path string, values url.Values, opts PageOptions) *Pager {
+	v := url.Values{}
+	for key, vals := range values {
+		v[key] = append([]string(nil), vals...)
+	}
+	if opts.PageSize > 0 {
+		v.Set("limit", strconv.Itoa(opts.PageSize))
+	}
+	if len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	return &Pager{ctx: ctx, client: c, next: path, opts: opts}
+}
+
+// Next advances the Pager to the next item fetching the next page if needed.
+// It returns false when there are no more items or an error occurred
+func (p *Pager) Next() bool {
+	if p.err != nil || (p.opts.Limit > 0 && p.count >= p.opts.Limit) {
+		return false
+	}
+	for len(p.items) == 0 {
+		if p.next == "" {
+			return false
+		}
+		if err := p.fetch(); err != nil {
+			p.err = err
+			return false
+		}
+	}
+	p.item, p.items = p.items[0], p.items[1:]
+	p.count++
+	return true
+}
+
+// Decode unmarshals the current item into v
+func (p *Pager) Decode(v interface{}) error {
+	return json.Unmarshal(p.item, v)
+}
+
+// Err returns the error occurred during the iteration
+func (p *Pager) Err() error {
+	return p.err
+}
+
+func (p *Pager) fetch() error {
+	current := p.next
+	response, err := p.client.GetContext(p.ctx, current)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := checkServiceError(response, http.StatusOK); err != nil {
+		return err
+	}
+
+	var pg page
+	if err := json.NewDecoder(response.Body).Decode(&pg); err != nil {
+		return err
+	}
+	p.items = append(pg.Items, pg.Results...)
+
+	link := pg.NextPage
+	if link == "" {
+		link = pg.NextPageURL
+	}
+	p.next, err = nextPagePath(link)
+	if err != nil {
+		return err
+	}
+	// protect from looping over the same empty page
+	if p.next == current && len(p.items) == 0 {
+		p.next = ""
+	}
+	return nil
+}
+
+// nextPagePath strips the host and the signature parameters from the link,
+// so the request for the next page is signed with the keys of the Client
+func nextPagePath(link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Del("api_key")
+	q.Del("expires")
+	q.Del("signature")
+	if len(q) == 0 {
+		return u.Path, nil
+	}
+	return u.Path + "?" + q.Encode(), nil
+}
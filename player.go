@@ -0,0 +1,34 @@
+package oo
+
+import (
+	"context"
+)
+
+// Player is a player configuration of the Ooyala account
+type Player struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// GetPlayers retreives the players of the Ooyala account following all the pages
+func (c Client) GetPlayers() ([]Player, error) {
+	return c.GetPlayersContext(context.Background())
+}
+
+// GetPlayersContext is the same as GetPlayers but with the given context
+func (c Client) GetPlayersContext(ctx context.Context) ([]Player, error) {
+	var players []Player
+	pager := c.Paginate(ctx, "/v2/players", nil, PageOptions{})
+	for pager.Next() {
+		var p Player
+		if err := pager.Decode(&p); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+	return players, nil
+}
@@ -0,0 +1,115 @@
+package oo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The statuses of the asset processing
+const (
+	StatusUploading  = "uploading"
+	StatusUploaded   = "uploaded"
+	StatusProcessing = "processing"
+	StatusLive       = "live"
+	StatusPaused     = "paused"
+	StatusError      = "error"
+	StatusFailed     = "failed"
+)
+
+// WaitOptions controls how the asset status is polled
+type WaitOptions struct {
+	// Timeout stops the waiting, 0 waits until the context is done
+	Timeout time.Duration
+	// MinInterval is the first pause between the polls, it doubles up to MaxInterval.
+	// The defaults are 5 seconds and 1 minute
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// OnStatus is called every time the status of the asset changes
+	OnStatus func(*Asset)
+}
+
+// ProcessingError is returned when the asset processing ends with an error status
+type ProcessingError struct {
+	EmbedCode string
+	Status    string
+}
+
+func (e *ProcessingError) Error() string {
+	return fmt.Sprintf("asset %v processing ended with status %v", e.EmbedCode, e.Status)
+}
+
+// WaitForStatus polls the asset until it gets one of the given statuses and returns it.
+// The pause between the polls grows while the status stays the same, and the polls
+// are held until the credits are reset when the rate limit is close
+func (c Client) WaitForStatus(ctx context.Context, ec string, opts WaitOptions, statuses ...string) (*Asset, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	if opts.MinInterval <= 0 {
+		opts.MinInterval = 5 * time.Second
+	}
+	if opts.MaxInterval < opts.MinInterval {
+		opts.MaxInterval = time.Minute
+		if opts.MaxInterval < opts.MinInterval {
+			opts.MaxInterval = opts.MinInterval
+		}
+	}
+
+	interval := opts.MinInterval
+	last := ""
+	for {
+		asset, err := c.GetAssetContext(ctx, ec)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("couldn't wait for asset %v status, last status %q: %w", ec, last, ctx.Err())
+			}
+			return nil, err
+		}
+		if asset.Status != last {
+			last = asset.Status
+			interval = opts.MinInterval
+			if opts.OnStatus != nil {
+				opts.OnStatus(asset)
+			}
+		}
+		for _, s := range statuses {
+			if asset.Status == s {
+				return asset, nil
+			}
+		}
+
+		wait := interval
+		if rl := c.RateLimit(); rl.Known && c.limiter.threshold > 0 && rl.Credits < c.limiter.threshold {
+			// don't spend the last credits on polling
+			if untilReset := time.Until(rl.Reset); untilReset > wait {
+				wait = untilReset
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("couldn't wait for asset %v status, last status %q: %w", ec, last, ctx.Err())
+		case <-timer.C:
+		}
+		if interval *= 2; interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// WatchProcessing waits until the asset processing is finished. It returns the asset
+// when it turns live or paused and *ProcessingError when it turns error or failed
+func (c Client) WatchProcessing(ctx context.Context, ec string, opts WaitOptions) (*Asset, error) {
+	asset, err := c.WaitForStatus(ctx, ec, opts, StatusLive, StatusPaused, StatusError, StatusFailed)
+	if err != nil {
+		return nil, err
+	}
+	if asset.Status == StatusError || asset.Status == StatusFailed {
+		return asset, &ProcessingError{EmbedCode: ec, Status: asset.Status}
+	}
+	return asset, nil
+}
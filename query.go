@@ -0,0 +1,247 @@
+package oo
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the layout of the time values in Backlot queries
+const TimeFormat = "2006-01-02T15:04:05Z"
+
+// Query builds where, orderby, limit, include and fields parameters of Backlot list requests.
+// All the methods return the Query itself, so the calls can be chained
+type Query struct {
+	where    []Cond
+	orderBy  []string
+	include  []string
+	fields   []string
+	pageSize int
+	limit    int
+	extra    url.Values
+}
+
+// NewQuery returns an empty Query
+func NewQuery() *Query {
+	return &Query{extra: url.Values{}}
+}
+
+// Where adds the conditions to the query joining them with AND
+func (q *Query) Where(conds ...Cond) *Query {
+	q.where = append(q.where, conds...)
+	return q
+}
+
+// OrderBy sorts the results by the field in ascending order
+func (q *Query) OrderBy(field string) *Query {
+	q.orderBy = append(q.orderBy, field+" ascending")
+	return q
+}
+
+// OrderByDesc sorts the results by the field in descending order
+func (q *Query) OrderByDesc(field string) *Query {
+	q.orderBy = append(q.orderBy, field+" descending")
+	return q
+}
+
+// Limit stops the iteration over the results after n items
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// PageSize sets the number of items requested per page
+func (q *Query) PageSize(n int) *Query {
+	q.pageSize = n
+	return q
+}
+
+// Include adds the related entities like labels or metadata to the results
+func (q *Query) Include(what ...string) *Query {
+	q.include = append(q.include, what...)
+	return q
+}
+
+// Fields restricts the fields of the returned items
+func (q *Query) Fields(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+// Set adds any other parameter to the query
+func (q *Query) Set(key, value string) *Query {
+	if q.extra == nil {
+		q.extra = url.Values{}
+	}
+	q.extra.Set(key, value)
+	return q
+}
+
+// Values returns the query parameters. The page size and the limit are not included
+func (q *Query) Values() url.Values {
+	v := url.Values{}
+	if q == nil {
+		return v
+	}
+	for key, vals := range q.extra {
+		v[key] = append([]string(nil), vals...)
+	}
+	if len(q.where) > 0 {
+		v.Set("where", And(q.where...).String())
+	}
+	if len(q.orderBy) > 0 {
+		v.Set("orderby", strings.Join(q.orderBy, ","))
+	}
+	if len(q.include) > 0 {
+		v.Set("include", strings.Join(q.include, ","))
+	}
+	if len(q.fields) > 0 {
+		v.Set("fields", strings.Join(q.fields, ","))
+	}
+	return v
+}
+
+// PageOptions returns the pagination options of the query
+func (q *Query) PageOptions() PageOptions {
+	if q == nil {
+		return PageOptions{}
+	}
+	return PageOptions{PageSize: q.pageSize, Limit: q.limit}
+}
+
+// Encode returns the query parameters in url encoded form
+func (q *Query) Encode() string {
+	v := q.Values()
+	if q != nil && q.pageSize > 0 {
+		v.Set("limit", strconv.Itoa(q.pageSize))
+	}
+	return v.Encode()
+}
+
+// PaginateQuery returns the Pager for the list endpoint by the given path and query
+func (c Client) PaginateQuery(ctx context.Context, path string, q *Query) *Pager {
+	return c.Paginate(ctx, path, q.Values(), q.PageOptions())
+}
+
+// Cond is a condition of the where parameter
+type Cond struct {
+	expr string
+	// compound is true if the condition joins others, so it needs parentheses when nested
+	compound bool
+}
+
+// String returns the condition as it is sent to Backlot
+func (c Cond) String() string {
+	return c.expr
+}
+
+// And joins the conditions with AND
+func And(conds ...Cond) Cond {
+	return join(" AND ", conds)
+}
+
+// Or joins the conditions with OR
+func Or(conds ...Cond) Cond {
+	return join(" OR ", conds)
+}
+
+func join(op string, conds []Cond) Cond {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	parts := make([]string, 0, len(conds))
+	for _, c := range conds {
+		if c.compound {
+			parts = append(parts, "("+c.expr+")")
+			continue
+		}
+		parts = append(parts, c.expr)
+	}
+	return Cond{expr: strings.Join(parts, op), compound: len(parts) > 1}
+}
+
+// FieldRef refers to an asset field in the conditions
+type FieldRef string
+
+// Field refers to an asset field like status, updated_at or labels
+func Field(name string) FieldRef {
+	return FieldRef(name)
+}
+
+// Metadata refers to a custom metadata key of the asset
+func Metadata(key string) FieldRef {
+	return FieldRef("metadata." + key)
+}
+
+// Labels refers to the labels of the asset, use it with Includes
+func Labels() FieldRef {
+	return FieldRef("labels")
+}
+
+// Eq is the condition field = value
+func (f FieldRef) Eq(v interface{}) Cond { return f.cond("=", v) }
+
+// Ne is the condition field != value
+func (f FieldRef) Ne(v interface{}) Cond { return f.cond("!=", v) }
+
+// Gt is the condition field > value
+func (f FieldRef) Gt(v interface{}) Cond { return f.cond(">", v) }
+
+// Ge is the condition field >= value
+func (f FieldRef) Ge(v interface{}) Cond { return f.cond(">=", v) }
+
+// Lt is the condition field < value
+func (f FieldRef) Lt(v interface{}) Cond { return f.cond("<", v) }
+
+// Le is the condition field <= value
+func (f FieldRef) Le(v interface{}) Cond { return f.cond("<=", v) }
+
+// Includes is the condition field INCLUDES value, used for labels
+func (f FieldRef) Includes(v interface{}) Cond { return f.cond(" INCLUDES ", v) }
+
+func (f FieldRef) cond(op string, v interface{}) Cond {
+	return Cond{expr: string(f) + op + formatValue(v)}
+}
+
+// formatValue quotes strings and times, the numbers and booleans are left as is.
+// The nil time pointers are written as the empty string
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return quote(val)
+	case time.Time:
+		return quote(val.UTC().Format(TimeFormat))
+	case Time:
+		return formatValue(val.Time)
+	case *time.Time:
+		if val == nil {
+			return quote("")
+		}
+		return formatValue(*val)
+	case *Time:
+		if val == nil {
+			return quote("")
+		}
+		return formatValue(val.Time)
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return quote(fmt.Sprint(val))
+	}
+}
+
+// quote wraps the string into single quotes escaping the quotes inside
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
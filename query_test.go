@@ -0,0 +1,32 @@
+package oo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatValue(t *testing.T) {
+	updated := time.Date(2019, 3, 4, 10, 20, 30, 0, time.FixedZone("CET", 3600))
+	asset := Asset{UpdatedAt: Time{updated}}
+	var nilTime *Time
+
+	tests := []struct {
+		cond Cond
+		want string
+	}{
+		{Field("name").Eq("it's"), `name='it\'s'`},
+		{Field("duration").Gt(60), "duration>60"},
+		{Field("updated_at").Gt(updated), "updated_at>'2019-03-04T09:20:30Z'"},
+		{Field("updated_at").Gt(asset.UpdatedAt), "updated_at>'2019-03-04T09:20:30Z'"},
+		{Field("updated_at").Le(&asset.UpdatedAt), "updated_at<='2019-03-04T09:20:30Z'"},
+		{Field("created_at").Lt(&updated), "created_at<'2019-03-04T09:20:30Z'"},
+		{Field("created_at").Eq(nilTime), "created_at=''"},
+		{And(Field("status").Eq("live"), Or(Labels().Includes("a"), Metadata("k").Ne(true))),
+			"status='live' AND (labels INCLUDES 'a' OR metadata.k!=true)"},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.String(); got != tt.want {
+			t.Errorf("condition is %v, want %v", got, tt.want)
+		}
+	}
+}
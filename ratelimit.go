@@ -0,0 +1,149 @@
+package oo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitThreshold is the default number of credits below which
+// the requests are held until the credits are reset
+const RateLimitThreshold = 100
+
+// RateLimitPause is the default time the requests are held
+// when the response doesn't tell when the credits are reset
+const RateLimitPause = time.Minute
+
+// RateLimit holds the state of the API credits reported
+// in X-RateLimit-Credits and X-RateLimit-Reset headers
+type RateLimit struct {
+	// Credits is the number of credits left
+	Credits int
+	// Reset is the time when the credits are restored
+	Reset time.Time
+	// Known is false until a response with the rate limit headers is received
+	Known bool
+}
+
+// rateLimiter is shared by all the copies of the Client
+// and throttles the requests sent from different goroutines
+type rateLimiter struct {
+	mu        sync.Mutex
+	state     RateLimit
+	threshold int
+	pause     time.Duration
+}
+
+func newRateLimiter(threshold int, pause time.Duration) *rateLimiter {
+	return &rateLimiter{threshold: threshold, pause: pause}
+}
+
+// WithRateLimit sets the number of credits below which the requests are held
+// until the credits are reset. The pause is used if the reset time is unknown.
+// Threshold 0 disables throttling, while the credits are still tracked.
+func WithRateLimit(threshold int, pause time.Duration) Option {
+	return func(cfg *clientConfig) {
+		cfg.limiter = newRateLimiter(threshold, pause)
+	}
+}
+
+// RateLimit returns the credits state reported in the last response
+func (c Client) RateLimit() RateLimit {
+	if c.limiter == nil {
+		return RateLimit{}
+	}
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	return c.limiter.state
+}
+
+// wait blocks until there are enough credits to send a request or the context is done
+func (l *rateLimiter) wait(ctx context.Context, out io.Writer) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		d := l.delay(time.Now())
+		if d <= 0 {
+			// reserve a credit, so the concurrent callers don't overrun the limit
+			if l.state.Known {
+				l.state.Credits--
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		credits := l.state.Credits
+		l.mu.Unlock()
+
+		fmt.Fprintf(out, "RATELIMIT: %v credits left, waiting for %v\n", credits, d.Round(time.Second))
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns how long the request has to wait. Should be called under the lock
+func (l *rateLimiter) delay(now time.Time) time.Duration {
+	if l.threshold <= 0 || !l.state.Known || l.state.Credits >= l.threshold {
+		return 0
+	}
+	if now.Before(l.state.Reset) {
+		return l.state.Reset.Sub(now)
+	}
+	// the credits have been restored but the actual number is unknown until the next response
+	l.state.Known = false
+	return 0
+}
+
+// update stores the credits state from the response headers
+func (l *rateLimiter) update(h http.Header, now time.Time) {
+	if l == nil {
+		return
+	}
+	rl, ok := rateLimitFromHeader(h, now)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rl.Reset.IsZero() && rl.Credits < l.threshold {
+		rl.Reset = now.Add(l.pause)
+	}
+	l.state = rl
+}
+
+// rateLimitFromHeader parses the credits state, ok is false if the headers are absent
+func rateLimitFromHeader(h http.Header, now time.Time) (rl RateLimit, ok bool) {
+	credits, err := strconv.Atoi(h.Get("X-RateLimit-Credits"))
+	if err != nil {
+		return rl, false
+	}
+	rl.Credits = credits
+	rl.Known = true
+	rl.Reset = parseReset(h.Get("X-RateLimit-Reset"), now)
+	return rl, true
+}
+
+// parseReset converts X-RateLimit-Reset value to time.
+// The value is treated as the number of seconds till reset,
+// unless it is big enough to be a unix timestamp
+func parseReset(v string, now time.Time) time.Time {
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}
+	}
+	if seconds > 1e9 {
+		return time.Unix(seconds, 0)
+	}
+	return now.Add(time.Duration(seconds) * time.Second)
+}
@@ -0,0 +1,230 @@
+package oo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// UploadState is the progress of an upload saved to the state file,
+// so the upload interrupted by a crash can be resumed sending only the missing chunks
+type UploadState struct {
+	EmbedCode   string `json:"embed_code"`
+	Replacement bool   `json:"replacement"`
+	Name        string `json:"name"`
+	FileName    string `json:"file_name"`
+	FileSize    int64  `json:"file_size"`
+	// ModTime is the modification time of the file, zero if the source is not a file
+	ModTime   time.Time `json:"mod_time"`
+	ChunkSize int       `json:"chunk_size"`
+	// Fingerprint identifies the uploaded content and the chunk size
+	Fingerprint string `json:"fingerprint"`
+	// URLs are kept to find out if the chunks are split the same way,
+	// they expire, so the resumed upload fetches the new ones
+	URLs []string `json:"urls"`
+	// Completed are the indices of the uploaded chunks
+	Completed []int `json:"completed"`
+}
+
+// LoadUploadState reads the state file, it returns nil state if the file doesn't exist
+func LoadUploadState(path string) (*UploadState, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s UploadState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("couldn't read upload state %v: %v", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state to the file. The file is replaced atomically,
+// so the crash during saving doesn't corrupt the previous state
+func (s *UploadState) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// IsCompleted reports if the chunk with the given index has been uploaded
+func (s *UploadState) IsCompleted(i int) bool {
+	for _, c := range s.Completed {
+		if c == i {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *UploadState) complete(i int) {
+	if s.IsCompleted(i) {
+		return
+	}
+	s.Completed = append(s.Completed, i)
+	sort.Ints(s.Completed)
+}
+
+// fingerprint identifies the content by its name, size, modification time and the sample of the content
+func fingerprint(source *Source, chunksize int) (string, error) {
+	sample, err := source.sample(chunksize)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%v|%v|%v|%v|%v", source.Name, source.Size, source.ModTime.UnixNano(), chunksize, sample)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SetStatePath enables resumable uploads. The progress of the upload is saved to the file
+// and the next CreateUploadAsset or ReplaceUploadAsset with the same file continues
+// the interrupted upload instead of starting over. The file is removed when the upload is done.
+func (u *Uploader) SetStatePath(path string) {
+	u.statePath = path
+}
+
+// resume returns the asset of the interrupted upload of the same source or nil if there is nothing to resume.
+// For replacement the embed code must match as well
+func (u *Uploader) resume(source *Source, chunksize int, embedCode string) (*Asset, error) {
+	u.state = nil
+	if u.statePath == "" {
+		return nil, nil
+	}
+	s, err := LoadUploadState(u.statePath)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	fp, err := fingerprint(source, chunksize)
+	if err != nil {
+		return nil, err
+	}
+	if s.Fingerprint != fp ||
+		s.Replacement != u.replacement || (u.replacement && s.EmbedCode != embedCode) {
+		// the state belongs to another upload
+		return nil, nil
+	}
+	u.state = s
+	fmt.Fprintf(u.client.out, "RESUME: %v, %v chunks uploaded already\n", s.EmbedCode, len(s.Completed))
+	return &Asset{
+		EmbedCode: s.EmbedCode,
+		Name:      s.Name,
+		FileName:  s.FileName,
+		source:    source,
+		chunksize: chunksize,
+	}, nil
+}
+
+// startState begins tracking of the new upload
+func (u *Uploader) startState(asset *Asset) error {
+	if u.statePath == "" {
+		return nil
+	}
+	source := asset.source
+	fp, err := fingerprint(source, asset.chunksize)
+	if err != nil {
+		return err
+	}
+	u.state = &UploadState{
+		EmbedCode:   asset.EmbedCode,
+		Replacement: u.replacement,
+		Name:        asset.Name,
+		FileName:    source.Name,
+		FileSize:    source.Size,
+		ModTime:     source.ModTime,
+		ChunkSize:   asset.chunksize,
+		Fingerprint: fp,
+	}
+	return u.state.Save(u.statePath)
+}
+
+// stateURLs stores the uploading urls. The uploaded chunks are forgotten
+// if the urls split the file differently than before
+func (u *Uploader) stateURLs(urls []*url.URL) {
+	if u.state == nil {
+		return
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	if !sameChunks(u.state.URLs, urls) {
+		u.state.Completed = nil
+	}
+	u.state.URLs = make([]string, 0, len(urls))
+	for _, url := range urls {
+		u.state.URLs = append(u.state.URLs, url.String())
+	}
+	u.saveState()
+}
+
+// sameChunks reports if the saved urls have the same chunk sizes as the fetched ones
+func sameChunks(saved []string, urls []*url.URL) bool {
+	if len(saved) != len(urls) {
+		return false
+	}
+	old, err := parseURLs(saved)
+	if err != nil {
+		return false
+	}
+	for i := range urls {
+		a, err1 := chunkLength(old[i])
+		b, err2 := chunkLength(urls[i])
+		if err1 != nil || err2 != nil || a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// stateCompleted reports if the chunk was uploaded before the upload was interrupted
+func (u *Uploader) stateCompleted(i int) bool {
+	if u.state == nil {
+		return false
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	return u.state.IsCompleted(i)
+}
+
+// stateComplete marks the chunk as uploaded
+func (u *Uploader) stateComplete(i int) {
+	if u.state == nil {
+		return
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	u.state.complete(i)
+	u.saveState()
+}
+
+// finishState removes the state file when the upload is done
+func (u *Uploader) finishState() {
+	if u.state == nil {
+		return
+	}
+	u.state = nil
+	if err := os.Remove(u.statePath); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(u.client.out, "RESUME: couldn't remove upload state: %v\n", err)
+	}
+}
+
+// saveState writes the state, the failure doesn't break the upload
+// but makes it impossible to resume. Should be called under the lock
+func (u *Uploader) saveState() {
+	if err := u.state.Save(u.statePath); err != nil {
+		fmt.Fprintf(u.client.out, "RESUME: couldn't save upload state: %v\n", err)
+	}
+}
@@ -0,0 +1,115 @@
+package oo_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dimdiden/oo"
+	"github.com/dimdiden/oo/oootest"
+)
+
+const resumeChunk = 1024
+
+// interruptedUpload starts the upload which fails on the second chunk and leaves the state file
+func interruptedUpload(t *testing.T, srv *oootest.Server, statePath string, source *oo.Source, ec string) {
+	t.Helper()
+	srv.Fail("PUT", "/oootest/upload/"+ec+"/1", 500, 1)
+	u := oo.NewUploader(srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 1})))
+	u.SetConcurrency(1)
+	u.SetStatePath(statePath)
+	if _, err := u.CreateUploadSource(source, "video", resumeChunk); err == nil {
+		t.Fatal("the interrupted upload succeeded")
+	}
+	state, err := oo.LoadUploadState(statePath)
+	if err != nil || state == nil {
+		t.Fatalf("no upload state is saved: %v", err)
+	}
+	if state.EmbedCode != ec || !state.IsCompleted(0) || state.IsCompleted(1) {
+		t.Fatalf("unexpected state %+v", state)
+	}
+}
+
+func chunkPuts(srv *oootest.Server, ec string, index string) int {
+	n := 0
+	for _, r := range srv.Requests() {
+		if r.Method == "PUT" && r.Path == "/oootest/upload/"+ec+"/"+index {
+			n++
+		}
+	}
+	return n
+}
+
+func TestResumeFetchesNewURLs(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "oo-resume-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+	content := bytes.Repeat([]byte("resumable"), 3*resumeChunk/9+1)
+
+	const ec = "oootest00000001"
+	interruptedUpload(t, srv, statePath, oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4"), ec)
+
+	// the saved urls have expired and point nowhere now
+	state, _ := oo.LoadUploadState(statePath)
+	for i, u := range state.URLs {
+		state.URLs[i] = strings.Replace(u, srv.URL, "http://127.0.0.1:1", 1)
+	}
+	if err := state.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	u := oo.NewUploader(srv.Client())
+	u.SetStatePath(statePath)
+	asset, err := u.CreateUploadSource(oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4"), "video", resumeChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.EmbedCode != ec {
+		t.Errorf("the upload created asset %v instead of resuming %v", asset.EmbedCode, ec)
+	}
+	if !bytes.Equal(srv.Content(ec), content) {
+		t.Error("the uploaded content differs from the source")
+	}
+	if n := chunkPuts(srv, ec, "0"); n != 1 {
+		t.Errorf("the first chunk is sent %v times, want once", n)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("the state file is left after the upload: %v", err)
+	}
+}
+
+func TestResumeOtherStreamOfSameSize(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "oo-resume-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+	first := bytes.Repeat([]byte("a"), 3*resumeChunk)
+	second := bytes.Repeat([]byte("b"), 3*resumeChunk)
+
+	interruptedUpload(t, srv, statePath, oo.NewStreamSource(bytes.NewReader(first), int64(len(first)), "-"), "oootest00000001")
+
+	u := oo.NewUploader(srv.Client())
+	u.SetStatePath(statePath)
+	asset, err := u.CreateUploadSource(oo.NewStreamSource(bytes.NewReader(second), int64(len(second)), "-"), "video", resumeChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.EmbedCode == "oootest00000001" {
+		t.Fatal("the other stream resumed the interrupted upload")
+	}
+	if !bytes.Equal(srv.Content(asset.EmbedCode), second) {
+		t.Error("the uploaded content differs from the stream")
+	}
+}
@@ -0,0 +1,204 @@
+package oo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how the requests failed with transient errors are repeated
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts for a request, 1 or less disables retries
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, it is doubled for every next one
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between the attempts, including the one asked by Retry-After header
+	MaxBackoff time.Duration
+	// RetryStatus is the set of http status codes to retry the request on
+	RetryStatus map[int]bool
+	// RetryPost allows to repeat POST requests on server and connection errors.
+	// It is off by default because the entity might have been already created.
+	// POST requests are always repeated on 429 status
+	RetryPost bool
+}
+
+// DefaultRetryPolicy returns the policy used by NewClient unless WithRetryPolicy is given
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		RetryStatus: map[int]bool{
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+	}
+}
+
+// WithRetryPolicy sets the policy for retrying failed requests
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(cfg *clientConfig) {
+		cfg.retry = &p
+	}
+}
+
+// retryable reports if the request should be repeated after the given result
+func (p RetryPolicy) retryable(req *http.Request, res *http.Response, err error) bool {
+	if err == nil && res.StatusCode == http.StatusTooManyRequests {
+		return p.RetryStatus[res.StatusCode]
+	}
+	if req.Method == http.MethodPost && !p.RetryPost {
+		return false
+	}
+	if err != nil {
+		return isTransient(err)
+	}
+	return p.RetryStatus[res.StatusCode]
+}
+
+// isTransient reports if the error is caused by timeout or dropped connection
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// backoff returns the delay before the next attempt. Retry-After header is honored
+// if present, but not longer than MaxBackoff
+func (p RetryPolicy) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if d, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+	d := p.MinBackoff << uint(attempt-1)
+	if d > p.MaxBackoff || d <= 0 {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// add jitter, so the concurrent requests don't retry at the same moment
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses Retry-After header given either in seconds or as http date
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// do sends the request returned by newRequest and repeats it according to the retry policy.
+// newRequest is called for every attempt, so the request can be signed with a fresh expires value.
+// If limited is true the attempts are throttled by the rate limiter.
+func (c Client) do(ctx context.Context, limited bool, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if limited {
+			// wait for the credits before signing, so the request doesn't expire meanwhile
+			if err := c.limiter.wait(ctx, c.out); err != nil {
+				return nil, err
+			}
+		}
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		res, err := c.HTTPClient().Do(req)
+		if err == nil {
+			if limited {
+				c.limiter.update(res.Header, time.Now())
+			}
+			// only the API reports its clock, the upload hosts may be off
+			if c.RootURL != nil && req.URL.Host == c.RootURL.Host {
+				c.clock.update(res.Header, sent, time.Now(), c.out)
+			}
+		}
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !c.retry.retryable(req, res, err) {
+			return res, err
+		}
+
+		wait := c.retry.backoff(attempt, res)
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = res.Status
+			// the body has to be read till the end to reuse the connection
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		fmt.Fprintf(c.out, "RETRY: %v %v failed with %v, attempt %v of %v in %v\n",
+			req.Method, req.URL.Path, reason, attempt+1, c.retry.MaxAttempts, wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rewindable returns a function which gives the body from the beginning on every call.
+// Seekable bodies are rewound in place, others are spooled once. The returned closer
+// removes the spooled copy when it is closed along with the bodies of all the requests
+func rewindable(body io.Reader) (func() (io.Reader, error), *sharedCloser, error) {
+	if body == nil {
+		return func() (io.Reader, error) { return nil, nil }, newSharedCloser(ioutil.NopCloser(nil)), nil
+	}
+	var closer io.Closer = ioutil.NopCloser(nil)
+	s, ok := body.(io.ReadSeeker)
+	var start int64
+	if ok {
+		var err error
+		start, err = s.Seek(0, io.SeekCurrent)
+		ok = err == nil
+	}
+	if !ok {
+		var err error
+		if s, closer, err = spool(body); err != nil {
+			return nil, nil, err
+		}
+		start = 0
+	}
+	return func() (io.Reader, error) {
+		if _, err := s.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}, newSharedCloser(closer), nil
+}
@@ -0,0 +1,20 @@
+package oo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffRetryAfter(t *testing.T) {
+	p := RetryPolicy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for value, want := range map[string]time.Duration{
+		"3":    3 * time.Second,
+		"3600": 10 * time.Second,
+	} {
+		res := &http.Response{Header: http.Header{"Retry-After": {value}}}
+		if got := p.backoff(1, res); got != want {
+			t.Errorf("backoff for Retry-After %v is %v, want %v", value, got, want)
+		}
+	}
+}
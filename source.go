@@ -0,0 +1,130 @@
+package oo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Source is the content uploaded for an asset. It is read either at random offsets
+// so the chunks can be read in any order, or sequentially from a stream like a pipe
+type Source struct {
+	// Name is the original file name reported to Backlot
+	Name string
+	// Size is the exact number of bytes of the content
+	Size int64
+	// ModTime is the modification time of the file, zero if the source is not a file.
+	// It is used to recognize the same content when the upload is resumed
+	ModTime time.Time
+
+	readerAt io.ReaderAt
+	stream   io.Reader
+	// offset is the position of the stream, it only moves forward
+	offset int64
+	closer io.Closer
+	// head is the beginning of the stream read ahead for the sample
+	head []byte
+}
+
+// FileSource returns the Source reading the file. The file is closed when the upload is finished
+func FileSource(file *os.File) (*Source, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &Source{
+		Name:     stat.Name(),
+		Size:     stat.Size(),
+		ModTime:  stat.ModTime(),
+		readerAt: file,
+		closer:   file,
+	}, nil
+}
+
+// NewReaderAtSource returns the Source reading size bytes from r,
+// for example an object of S3 compatible storage or an in-memory buffer
+func NewReaderAtSource(r io.ReaderAt, size int64, name string) *Source {
+	return &Source{Name: name, Size: size, readerAt: r}
+}
+
+// NewStreamSource returns the Source reading size bytes from the stream,
+// for example the output of ffmpeg. The chunks are read one after another,
+// so the stream must produce exactly size bytes
+func NewStreamSource(r io.Reader, size int64, name string) *Source {
+	return &Source{Name: name, Size: size, stream: r}
+}
+
+// section returns the reader of the chunk by the given offset and size.
+// The stream can't go back, so the chunks must be requested in order
+func (s *Source) section(offset, size int64) (io.Reader, error) {
+	if s.readerAt != nil {
+		return io.NewSectionReader(s.readerAt, offset, size), nil
+	}
+	if offset < s.offset {
+		return nil, fmt.Errorf("couldn't read stream at %v: already read up to %v", offset, s.offset)
+	}
+	// skip the chunks which are uploaded already
+	if skip := offset - s.offset; skip > 0 {
+		n, err := io.CopyN(ioutil.Discard, s.stream, skip)
+		s.offset += n
+		if err != nil {
+			return nil, fmt.Errorf("couldn't skip stream to %v: %v", offset, err)
+		}
+	}
+	s.offset += size
+	return io.LimitReader(s.stream, size), nil
+}
+
+// sample returns SHA256 of the first and the last chunk, so the different content of the same size
+// isn't taken for the resumed one. The stream can't go back, so only its first chunk is read ahead
+// and kept to be uploaded later
+func (s *Source) sample(chunksize int) (string, error) {
+	first := int64(chunksize)
+	if first > s.Size || first <= 0 {
+		first = s.Size
+	}
+	h := sha256.New()
+	if s.readerAt != nil {
+		last := s.Size % first
+		if last == 0 {
+			last = first
+		}
+		if _, err := io.Copy(h, io.NewSectionReader(s.readerAt, 0, first)); err != nil {
+			return "", fmt.Errorf("couldn't read the first chunk: %v", err)
+		}
+		if _, err := io.Copy(h, io.NewSectionReader(s.readerAt, s.Size-last, last)); err != nil {
+			return "", fmt.Errorf("couldn't read the last chunk: %v", err)
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if s.head == nil {
+		if s.offset != 0 {
+			return "", fmt.Errorf("couldn't sample stream read up to %v", s.offset)
+		}
+		head := make([]byte, first)
+		if _, err := io.ReadFull(s.stream, head); err != nil {
+			return "", fmt.Errorf("couldn't read the first chunk: %v", err)
+		}
+		s.head = head
+		s.stream = io.MultiReader(bytes.NewReader(head), s.stream)
+	}
+	if first > int64(len(s.head)) {
+		first = int64(len(s.head))
+	}
+	h.Write(s.head[:first])
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// close closes the file of the source, the readers given by the caller are left open
+func (s *Source) close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
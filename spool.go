@@ -0,0 +1,110 @@
+package oo
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// spoolLimit is the size of the body kept in memory, the bigger bodies are copied to a temporary file
+const spoolLimit = 1 << 20
+
+// spool copies the reader which can't seek to a seekable one, so the content can be read again.
+// The content up to spoolLimit is kept in memory, otherwise it is written to a temporary file.
+// The returned reader must be closed to remove the file
+func spool(r io.Reader) (io.ReadSeeker, io.Closer, error) {
+	head, err := ioutil.ReadAll(io.LimitReader(r, spoolLimit+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(head) <= spoolLimit {
+		return bytes.NewReader(head), ioutil.NopCloser(nil), nil
+	}
+	f, err := ioutil.TempFile("", "oo-body-")
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp := &tempFile{f}
+	if _, err := f.Write(head); err != nil {
+		tmp.Close()
+		return nil, nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		tmp.Close()
+		return nil, nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, nil, err
+	}
+	return tmp, tmp, nil
+}
+
+// tempFile is removed once it is closed
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// readCloser reads from one reader and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// sharedCloser closes the spooled body once its owner and all the requests reading it release it.
+// The transport may still be sending the body of a request after the response is received,
+// so the copy can't be removed when the owner is done with it
+type sharedCloser struct {
+	mu     sync.Mutex
+	refs   int
+	closer io.Closer
+}
+
+func newSharedCloser(closer io.Closer) *sharedCloser {
+	return &sharedCloser{refs: 1, closer: closer}
+}
+
+// acquire returns the closer of the request body, it closes body and releases the reference
+func (s *sharedCloser) acquire(body io.Closer) io.Closer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs++
+	return &bodyCloser{body: body, shared: s}
+}
+
+// Close releases the reference of the owner
+func (s *sharedCloser) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refs--; s.refs > 0 {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// bodyCloser releases the reference to the shared body once, however many times it is closed
+type bodyCloser struct {
+	once   sync.Once
+	body   io.Closer
+	shared *sharedCloser
+}
+
+func (c *bodyCloser) Close() error {
+	err := c.body.Close()
+	c.once.Do(func() {
+		if sErr := c.shared.Close(); err == nil {
+			err = sErr
+		}
+	})
+	return err
+}
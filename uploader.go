@@ -2,9 +2,11 @@ package oo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,26 +20,61 @@ type Uploader struct {
 	client      *Client
 	replacement bool
 	pp          string
-	wg          *sync.WaitGroup
-	requests    chan *http.Request
 	// startFunc, filterFunc, deferFunc are used to hook into a process of chunks upload
 	// can be usefull to visualize this process
 	startFunc  func() error
 	filterFunc func(*http.Request) (*http.Request, error)
 	deferFunc  func()
+	// statePath is the file where the progress is saved to resume the upload
+	statePath string
+	state     *UploadState
+	stateMu   sync.Mutex
+	// concurrency is the number of chunks uploaded at the same time
+	concurrency int
+	// memoryLimit caps the total size of the chunks read into memory
+	memoryLimit int64
+	// checksums are the hashes computed for the chunks and the whole file
+	checksums ChecksumType
+	// contentMD5 sends the chunks with Content-MD5 header
+	contentMD5 bool
+	// verify compares the uploaded file with the one reported by Backlot
+	verify bool
+	// eventFunc receives the progress events, eventMu delivers them one at a time
+	eventFunc func(Event)
+	eventMu   sync.Mutex
+	// dedupe looks for the existing asset before the new one is created
+	dedupe DedupeOptions
 }
 
+// DefaultConcurrency is the number of chunks uploaded at the same time by default
+const DefaultConcurrency = 4
+
 // NewUploader returns a new Uploader with a given client
 func NewUploader(client *Client) *Uploader {
 	return &Uploader{
 		client:      client,
 		replacement: false,
 		pp:          "",
-		wg:          &sync.WaitGroup{},
-		requests:    make(chan *http.Request),
+		concurrency: DefaultConcurrency,
 	}
 }
 
+// SetConcurrency sets the number of chunks uploaded at the same time
+func (u *Uploader) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	u.concurrency = n
+}
+
+// SetMemoryLimit sets the total size in bytes of the chunks kept in memory at the same time.
+// Only the chunks of the stream sources are buffered, the files and io.ReaderAt sources
+// are read while the chunks are sent. The next chunk is read only when the uploaded ones free enough memory.
+// A chunk bigger than the limit is still uploaded alone. 0 means no limit besides the concurrency
+func (u *Uploader) SetMemoryLimit(limit int64) {
+	u.memoryLimit = limit
+}
+
 // SetPP sets a processing profile the asset will be processed with
 func (u *Uploader) SetPP(pp string) {
 	u.pp = pp
@@ -49,7 +86,7 @@ func (u *Uploader) SetStartFunc(f func() error) {
 }
 
 // SetFilterFunc sets a function which is used to modify requests with chunks before seding them.
-// Can be usefull to visualize this process
+// It is called for every attempt including the retries. Can be usefull to visualize this process
 func (u *Uploader) SetFilterFunc(f func(*http.Request) (*http.Request, error)) {
 	u.filterFunc = f
 }
@@ -62,91 +99,220 @@ func (u *Uploader) SetDeferFunc(f func()) {
 // CreateUploadAsset creates an asset in Ooyala account,
 // uploads file for this asset and triggers the transcoding job
 func (u *Uploader) CreateUploadAsset(file *os.File, name string, chunksize int) (*Asset, error) {
+	return u.CreateUploadAssetContext(context.Background(), file, name, chunksize)
+}
+
+// CreateUploadAssetContext is the same as CreateUploadAsset but with the given context.
+// Cancelling the context aborts the upload of the chunks which are still in progress.
+func (u *Uploader) CreateUploadAssetContext(ctx context.Context, file *os.File, name string, chunksize int) (*Asset, error) {
+	source, err := FileSource(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read file: %w", err)
+	}
+	return u.CreateUploadSourceContext(ctx, source, name, chunksize)
+}
+
+// CreateUploadSource is the same as CreateUploadAsset but the video is read from the given source,
+// so it can be uploaded from any io.ReaderAt or a stream of the known size
+func (u *Uploader) CreateUploadSource(source *Source, name string, chunksize int) (*Asset, error) {
+	return u.CreateUploadSourceContext(context.Background(), source, name, chunksize)
+}
+
+// CreateUploadSourceContext is the same as CreateUploadSource but with the given context
+func (u *Uploader) CreateUploadSourceContext(ctx context.Context, source *Source, name string, chunksize int) (*Asset, error) {
 	u.replacement = false
-	asset, err := u.client.CreateAsset(file, name, chunksize)
+	asset, err := u.resume(source, chunksize, "")
 	if err != nil {
-		return nil, fmt.Errorf("couldn't create asset: %v", err)
+		return nil, fmt.Errorf("couldn't resume upload: %w", err)
+	}
+	if asset == nil {
+		existing, err := u.findDuplicate(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return u.ReplaceUploadSourceContext(ctx, source, chunksize, existing.EmbedCode)
+		}
+		asset, err = u.client.CreateAssetSourceContext(ctx, source, name, chunksize)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create asset: %w", err)
+		}
+		u.emit(AssetCreated, asset.EmbedCode)
+		if err := u.startState(asset); err != nil {
+			return nil, fmt.Errorf("couldn't save upload state: %w", err)
+		}
 	}
 
-	if err := u.Upload(asset); err != nil {
-		return nil, fmt.Errorf("couldn't upload asset: %v", err)
+	if err := u.UploadContext(ctx, asset); err != nil {
+		return nil, fmt.Errorf("couldn't upload asset: %w", err)
 	}
 
-	if err := u.TriggerProcessing(asset.EmbedCode); err != nil {
-		return nil, fmt.Errorf("couldn't trigger asset processing: %v", err)
+	if err := u.TriggerProcessingContext(ctx, asset.EmbedCode); err != nil {
+		return nil, fmt.Errorf("couldn't trigger asset processing: %w", err)
 	}
 
+	u.finishState()
+	if err := u.markAsset(ctx, asset); err != nil {
+		return asset, fmt.Errorf("couldn't save asset dedupe fields: %w", err)
+	}
+	if u.verify {
+		if err := u.VerifyUpload(ctx, asset); err != nil {
+			return asset, fmt.Errorf("couldn't verify upload: %w", err)
+		}
+	}
 	return asset, nil
 }
 
 // ReplaceUploadAsset prepares an asset for replacement in Ooyala account,
 // uploads file for this asset and triggers the transcoding job
 func (u *Uploader) ReplaceUploadAsset(file *os.File, chunksize int, embedCode string) (*Asset, error) {
+	return u.ReplaceUploadAssetContext(context.Background(), file, chunksize, embedCode)
+}
+
+// ReplaceUploadAssetContext is the same as ReplaceUploadAsset but with the given context.
+// Cancelling the context aborts the upload of the chunks which are still in progress.
+func (u *Uploader) ReplaceUploadAssetContext(ctx context.Context, file *os.File, chunksize int, embedCode string) (*Asset, error) {
+	source, err := FileSource(file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read file: %w", err)
+	}
+	return u.ReplaceUploadSourceContext(ctx, source, chunksize, embedCode)
+}
+
+// ReplaceUploadSource is the same as ReplaceUploadAsset but the video is read from the given source
+func (u *Uploader) ReplaceUploadSource(source *Source, chunksize int, embedCode string) (*Asset, error) {
+	return u.ReplaceUploadSourceContext(context.Background(), source, chunksize, embedCode)
+}
+
+// ReplaceUploadSourceContext is the same as ReplaceUploadSource but with the given context
+func (u *Uploader) ReplaceUploadSourceContext(ctx context.Context, source *Source, chunksize int, embedCode string) (*Asset, error) {
 	u.replacement = true
-	asset, err := u.client.ReplaceAsset(file, chunksize, embedCode)
+	asset, err := u.resume(source, chunksize, embedCode)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't replace asset: %v", err)
+		return nil, fmt.Errorf("couldn't resume upload: %w", err)
+	}
+	if asset == nil {
+		asset, err = u.client.ReplaceAssetSourceContext(ctx, source, chunksize, embedCode)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't replace asset: %w", err)
+		}
+		u.emit(AssetCreated, asset.EmbedCode)
+		if err := u.startState(asset); err != nil {
+			return nil, fmt.Errorf("couldn't save upload state: %w", err)
+		}
 	}
 
-	if err := u.Upload(asset); err != nil {
-		return nil, fmt.Errorf("couldn't upload asset: %v", err)
+	if err := u.UploadContext(ctx, asset); err != nil {
+		return nil, fmt.Errorf("couldn't upload asset: %w", err)
 	}
 
-	if err := u.TriggerProcessing(asset.EmbedCode); err != nil {
-		return nil, fmt.Errorf("couldn't trigger asset processing: %v", err)
+	if err := u.TriggerProcessingContext(ctx, asset.EmbedCode); err != nil {
+		return nil, fmt.Errorf("couldn't trigger asset processing: %w", err)
 	}
 
+	u.finishState()
+	if err := u.markAsset(ctx, asset); err != nil {
+		return asset, fmt.Errorf("couldn't save asset dedupe fields: %w", err)
+	}
+	if u.verify {
+		if err := u.VerifyUpload(ctx, asset); err != nil {
+			return asset, fmt.Errorf("couldn't verify upload: %w", err)
+		}
+	}
 	return asset, nil
 }
 
 // Upload uploads file for the asset
 func (u *Uploader) Upload(asset *Asset) error {
+	return u.UploadContext(context.Background(), asset)
+}
+
+// UploadContext is the same as Upload but with the given context.
+// The first failed chunk or the cancellation of the context stops all other chunks.
+// The chunks uploaded before the interruption are skipped if the state path is set.
+func (u *Uploader) UploadContext(ctx context.Context, asset *Asset) error {
 	if u.deferFunc != nil {
 		defer u.deferFunc()
 	}
-	defer asset.file.Close()
+	if asset.source == nil {
+		return fmt.Errorf("no file attached")
+	}
+	defer asset.source.close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	urls, err := u.getURLs(asset.EmbedCode)
+	// the urls are signed and expire, so they are fetched again even if the upload is resumed
+	urls, err := u.getURLs(ctx, asset.EmbedCode)
 	if err != nil {
-		return fmt.Errorf("couldn't get uploading urls: %v", err)
+		return fmt.Errorf("couldn't get uploading urls: %w", err)
 	}
+	u.stateURLs(urls)
 
-	u.wg.Add(len(urls))
+	if err := checkChunks(urls, asset.source.Size); err != nil {
+		return err
+	}
 
-	done := make(chan bool, 1)
-	errs := make(chan error, 1)
+	requests := make(chan chunkRequest)
+	// errs is buffered enough to never block the senders
+	errs := make(chan error, len(urls)+1)
+	mem := newMemBudget(u.memoryLimit)
+	sums := newChecksummer(u.checksumTypes())
+	p := u.newProgress(asset.EmbedCode, asset.source.Size)
 
-	go u.pushRequests(asset.file, urls, errs)
-
-	go func() {
-		u.wg.Wait()
-		close(done)
-	}()
+	go u.pushRequests(ctx, asset.source, urls, mem, sums, p, requests, errs)
 
 	if u.startFunc != nil {
 		u.startFunc()
 	}
 
-	for request := range u.requests {
-		go u.uploadChunk(request, errs)
+	// the workers take the chunks one by one, so only a few of them are in memory
+	var wg sync.WaitGroup
+	for i := 0; i < u.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for request := range requests {
+				u.uploadChunk(request, p, errs)
+				mem.release(request.reserved)
+			}
+		}()
 	}
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-	for {
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-done:
+	}
+	// stop the rest of the chunks and wait for the workers before closing the file
+	cancel()
+	<-done
+	if err == nil {
+		// pushRequests could have failed before any chunk was sent
 		select {
-		case <-done:
-			return nil
-		case err := <-errs:
-			return err
+		case err = <-errs:
+		default:
 		}
 	}
+	if err == nil {
+		asset.checksums = sums.result()
+		u.emit(UploadCompleted, asset.EmbedCode)
+	}
+	return err
 }
 
-func (u *Uploader) getURLs(embedCode string) ([]*url.URL, error) {
+func (u *Uploader) getURLs(ctx context.Context, embedCode string) ([]*url.URL, error) {
 	q := "/v2/assets/" + embedCode + "/uploading_urls"
 	if u.replacement {
 		q = "/v2/assets/" + embedCode + "/replacement/uploading_urls"
 	}
-	response, err := u.client.Get(q)
+	response, err := u.client.GetContext(ctx, q)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +327,10 @@ func (u *Uploader) getURLs(embedCode string) ([]*url.URL, error) {
 	if err := dec.Decode(&rawurls); err != nil {
 		return nil, err
 	}
+	return parseURLs(rawurls)
+}
 
+func parseURLs(rawurls []string) ([]*url.URL, error) {
 	var urls []*url.URL
 	for _, rawurl := range rawurls {
 		url, err := url.Parse(rawurl)
@@ -173,75 +342,282 @@ func (u *Uploader) getURLs(embedCode string) ([]*url.URL, error) {
 	return urls, nil
 }
 
-func (u *Uploader) pushRequests(r io.Reader, urls []*url.URL, errs chan error) {
-	defer close(u.requests)
+// chunkRequest is the request with the chunk by the index
+type chunkRequest struct {
+	*http.Request
+	index int
+	// reserved is the memory taken from the budget for the chunk
+	reserved int64
+}
+
+// pushRequests reads the chunks on demand as the workers take them
+func (u *Uploader) pushRequests(ctx context.Context, source *Source, urls []*url.URL, mem *memBudget, sums *checksummer, p *progress, requests chan<- chunkRequest, errs chan<- error) {
+	defer close(requests)
 
 	if len(urls) == 0 {
 		errs <- fmt.Errorf("no uploading urls to perform upload")
 		return
 	}
-	if r == nil {
-		errs <- fmt.Errorf("no file attached")
-		return
-	}
 
-	for _, url := range urls {
-		chunk, err := getChunk(r, url)
+	var offset int64
+	for i, url := range urls {
+		size, err := chunkLength(url)
 		if err != nil {
 			errs <- err
 			return
 		}
-		request, err := http.NewRequest(http.MethodPut, url.String(), chunk)
+		if u.stateCompleted(i) {
+			if sums.enabled() {
+				// the whole file checksum needs the chunks uploaded before the resume
+				if err := u.hashChunk(source, offset, size, i, sums); err != nil {
+					errs <- err
+					return
+				}
+			}
+			offset += size
+			p.skip(size)
+			continue
+		}
+
+		request, reserved, err := u.chunkRequest(ctx, source, url, offset, size, i, mem, sums)
 		if err != nil {
-			errs <- err
+			if ctx.Err() == nil {
+				errs <- err
+			}
 			return
 		}
-		request.ContentLength = int64(chunk.Len())
-		if u.filterFunc != nil {
-			r, err := u.filterFunc(request)
+		offset += size
+		select {
+		case requests <- chunkRequest{request, i, reserved}:
+		case <-ctx.Done():
+			mem.release(reserved)
+			return
+		}
+	}
+}
+
+// chunkRequest returns the request uploading the chunk by the given offset and size.
+// The chunk of the source which can be read at any offset is read while it is sent,
+// GetBody reads it again for the retries. The chunk of the stream is buffered
+// within the memory budget, reserved is the memory taken for it
+func (u *Uploader) chunkRequest(ctx context.Context, source *Source, url *url.URL, offset, size int64, index int, mem *memBudget, sums *checksummer) (request *http.Request, reserved int64, err error) {
+	var getBody func() (io.ReadCloser, error)
+	var sum ChunkChecksum
+	if source.readerAt != nil {
+		getBody = func() (io.ReadCloser, error) {
+			section, err := source.section(offset, size)
 			if err != nil {
-				errs <- err
+				return nil, err
+			}
+			return ioutil.NopCloser(section), nil
+		}
+		if sums.enabled() {
+			section, err := source.section(offset, size)
+			if err != nil {
+				return nil, 0, err
+			}
+			if sum, err = sums.add(index, section, size); err != nil {
+				return nil, 0, err
+			}
+		}
+	} else {
+		// the stream can't be read again, so the chunk is kept in memory till it is uploaded
+		if reserved, err = mem.acquire(ctx, size); err != nil {
+			return nil, 0, err
+		}
+		defer func() {
+			if err != nil {
+				mem.release(reserved)
+			}
+		}()
+		section, err := source.section(offset, size)
+		if err != nil {
+			return nil, reserved, err
+		}
+		chunk, err := getChunk(section, size)
+		if err != nil {
+			return nil, reserved, err
+		}
+		getBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(chunk)), nil
+		}
+		if sums.enabled() {
+			if sum, err = sums.add(index, bytes.NewReader(chunk), size); err != nil {
+				return nil, reserved, err
 			}
-			request = r
 		}
-		u.requests <- request
 	}
-}
 
-func getChunk(r io.Reader, url *url.URL) (*bytes.Buffer, error) {
-	q := url.Query()
-	chunksize := q.Get("filesize")
-	i, err := strconv.Atoi(chunksize)
+	body, err := getBody()
 	if err != nil {
-		return nil, fmt.Errorf("couldn't get chunksize from url: %v", err)
+		return nil, reserved, err
 	}
-	chunk := make([]byte, i)
-	_, err = r.Read(chunk)
+	request, err = http.NewRequestWithContext(ctx, http.MethodPut, url.String(), body)
 	if err != nil {
-		// TODO: check if EOF check is needed
+		return nil, reserved, err
+	}
+	request.GetBody = getBody
+	request.ContentLength = size
+	if u.contentMD5 && sum.MD5 != "" {
+		request.Header.Set("Content-MD5", contentMD5(sum.MD5))
+	}
+	return request, reserved, nil
+}
+
+// memBudget limits the total size of the chunks in memory.
+// It is acquired by the single reader and released by the workers
+type memBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	freed chan struct{}
+}
+
+func newMemBudget(limit int64) *memBudget {
+	return &memBudget{limit: limit, freed: make(chan struct{}, 1)}
+}
+
+// acquire waits until there is enough memory for n bytes and returns the reserved amount
+func (b *memBudget) acquire(ctx context.Context, n int64) (int64, error) {
+	if b.limit <= 0 {
+		return 0, nil
+	}
+	if n > b.limit {
+		n = b.limit
+	}
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return n, nil
+		}
+		b.mu.Unlock()
+		select {
+		case <-b.freed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+func (b *memBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	select {
+	case b.freed <- struct{}{}:
+	default:
+	}
+}
+
+// chunkLength returns the size of the chunk encoded in the uploading url
+func chunkLength(url *url.URL) (int64, error) {
+	size, err := strconv.ParseInt(url.Query().Get("filesize"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get chunksize from url: %v", err)
+	}
+	return size, nil
+}
+
+// getChunk reads exactly size bytes, the short read is an error rather than a truncated chunk
+func getChunk(r io.Reader, size int64) ([]byte, error) {
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(r, chunk); err != nil {
 		return nil, fmt.Errorf("couldn't read chunk from reader: %v", err)
 	}
-	return bytes.NewBuffer(chunk), nil
+	return chunk, nil
 }
 
-func (u *Uploader) uploadChunk(request *http.Request, errs chan error) {
-	defer u.wg.Done()
-	response, err := http.DefaultClient.Do(request)
+// hashChunk reads the chunk uploaded already only to add it to the checksums
+func (u *Uploader) hashChunk(source *Source, offset, size int64, index int, sums *checksummer) error {
+	section, err := source.section(offset, size)
 	if err != nil {
-		errs <- err
-		return
+		return err
+	}
+	_, err = sums.add(index, section, size)
+	return err
+}
+
+// checkChunks makes sure the uploading urls split exactly the whole file
+func checkChunks(urls []*url.URL, size int64) error {
+	var total int64
+	for _, url := range urls {
+		n, err := chunkLength(url)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return fmt.Errorf("invalid chunk size %v in uploading url", n)
+		}
+		total += n
+	}
+	if len(urls) > 0 && total != size {
+		return fmt.Errorf("uploading urls cover %v bytes while file size is %v", total, size)
+	}
+	return nil
+}
+
+func (u *Uploader) uploadChunk(chunk chunkRequest, p *progress, errs chan<- error) {
+	request := chunk.Request
+	size := request.ContentLength
+	p.chunk(ChunkStarted, chunk.index, size, 0, 1, nil)
+	// the uploading urls are signed already, so the same request is sent again with the body restored.
+	// The filter is applied to every attempt, so the body wrapped by it isn't lost on the retries
+	attempt := 0
+	response, err := u.client.do(request.Context(), false, func() (*http.Request, error) {
+		attempt++
+		req := request.Clone(request.Context())
+		if attempt > 1 {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+			p.chunk(ChunkRetried, chunk.index, size, 0, attempt, nil)
+		}
+		if u.filterFunc != nil {
+			r, err := u.filterFunc(req)
+			if err != nil {
+				return nil, err
+			}
+			req = r
+		}
+		if u.eventFunc != nil && req.Body != nil {
+			a := attempt
+			req.Body = &progressBody{ReadCloser: req.Body, report: func(sent int64) {
+				p.chunk(ChunkProgress, chunk.index, size, sent, a, nil)
+			}}
+		}
+		return req, nil
+	})
+	if err == nil {
+		defer response.Body.Close()
+		err = checkServiceError(response, http.StatusNoContent)
 	}
-	if err := checkServiceError(response, http.StatusNoContent); err != nil {
+	if err != nil {
+		p.chunk(ChunkFailed, chunk.index, size, 0, attempt, err)
 		errs <- err
+		return
 	}
+	u.stateComplete(chunk.index)
+	p.chunk(ChunkCompleted, chunk.index, size, size, attempt, nil)
 }
 
 // TriggerProcessing starts the transcoding process for an asset by the given embed code
 func (u *Uploader) TriggerProcessing(embedCode string) error {
+	return u.TriggerProcessingContext(context.Background(), embedCode)
+}
+
+// TriggerProcessingContext is the same as TriggerProcessing but with the given context
+func (u *Uploader) TriggerProcessingContext(ctx context.Context, embedCode string) error {
 	// changing processing profile before triggering job
 	if u.pp != "" {
-		if err := u.setProcessingProfile(embedCode); err != nil {
-			return fmt.Errorf("couldn't set processing profile: %v", err)
+		if err := u.setProcessingProfile(ctx, embedCode); err != nil {
+			return fmt.Errorf("couldn't set processing profile: %w", err)
 		}
 	}
 	// triggering job
@@ -249,7 +625,7 @@ func (u *Uploader) TriggerProcessing(embedCode string) error {
 	if u.replacement {
 		q = "/v2/assets/" + embedCode + "/replacement/upload_status"
 	}
-	response, err := u.client.Put(q, strings.NewReader(`{"status":"uploaded"}`))
+	response, err := u.client.PutContext(ctx, q, strings.NewReader(`{"status":"uploaded"}`))
 	if err != nil {
 		return err
 	}
@@ -258,16 +634,18 @@ func (u *Uploader) TriggerProcessing(embedCode string) error {
 	if err := checkServiceError(response, http.StatusOK); err != nil {
 		return err
 	}
+	u.emit(ProcessingTriggered, embedCode)
 	return nil
 }
 
-func (u *Uploader) setProcessingProfile(embedCode string) error {
+func (u *Uploader) setProcessingProfile(ctx context.Context, embedCode string) error {
 	q := "/v2/assets/" + embedCode + "/processing_profile"
 	body := fmt.Sprintf(`{"processing_profile_id":"%v"}`, u.pp)
-	response, err := u.client.Post(q, strings.NewReader(body))
+	response, err := u.client.PostContext(ctx, q, strings.NewReader(body))
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	if err := checkServiceError(response, http.StatusOK); err != nil {
 		return err
 	}
@@ -276,10 +654,27 @@ func (u *Uploader) setProcessingProfile(embedCode string) error {
 
 // UploadImage uploads a thumbnail image for an asset by the given embed code
 func (u *Uploader) UploadImage(file *os.File, embedCode string) error {
-	response, err := u.client.Post("/v2/assets/"+embedCode+"/preview_image_files", file)
+	return u.UploadImageContext(context.Background(), file, embedCode)
+}
+
+// UploadImageContext is the same as UploadImage but with the given context
+func (u *Uploader) UploadImageContext(ctx context.Context, file *os.File, embedCode string) error {
+	return u.UploadImageReaderContext(ctx, file, embedCode)
+}
+
+// UploadImageReader is the same as UploadImage but the image is read from any reader.
+// The reader which can't seek is spooled to be sent again on retries
+func (u *Uploader) UploadImageReader(r io.Reader, embedCode string) error {
+	return u.UploadImageReaderContext(context.Background(), r, embedCode)
+}
+
+// UploadImageReaderContext is the same as UploadImageReader but with the given context
+func (u *Uploader) UploadImageReaderContext(ctx context.Context, r io.Reader, embedCode string) error {
+	response, err := u.client.PostContext(ctx, "/v2/assets/"+embedCode+"/preview_image_files", r)
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 	if err := checkServiceError(response, http.StatusOK); err != nil {
 		return err
 	}
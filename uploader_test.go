@@ -0,0 +1,121 @@
+package oo_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dimdiden/oo"
+	"github.com/dimdiden/oo/oootest"
+)
+
+func TestUploadSources(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	sum := md5.Sum(content)
+	sources := []struct {
+		name       string
+		source     func() *oo.Source
+		contentMD5 bool
+	}{
+		{"reader at", func() *oo.Source {
+			return oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4")
+		}, true},
+		{"stream", func() *oo.Source {
+			return oo.NewStreamSource(bytes.NewBuffer(content), int64(len(content)), "video.mp4")
+		}, false},
+	}
+	for _, s := range sources {
+		srv := oootest.NewServer("apikey", "secret")
+		// the failed chunks are sent again with the body read from the beginning
+		srv.Fail("PUT", "/oootest/upload/", 503, 2)
+
+		u := oo.NewUploader(srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 3, RetryStatus: map[int]bool{503: true}})))
+		u.SetChecksums(oo.MD5)
+		u.SetContentMD5(s.contentMD5)
+		u.SetMemoryLimit(4096)
+		asset, err := u.CreateUploadSource(s.source(), "video", 4096)
+		if err != nil {
+			t.Fatalf("%v: %v", s.name, err)
+		}
+		if !bytes.Equal(srv.Content(asset.EmbedCode), content) {
+			t.Errorf("%v: the uploaded content differs from the source", s.name)
+		}
+		sums := asset.Checksums()
+		if sums == nil || sums.MD5 != hex.EncodeToString(sum[:]) || len(sums.Chunks) != 4 {
+			t.Errorf("%v: unexpected checksums %+v", s.name, sums)
+		}
+		for _, r := range srv.Requests() {
+			if strings.HasPrefix(r.Path, "/oootest/upload/") && (r.Header.Get("Content-MD5") != "") != s.contentMD5 {
+				t.Errorf("%v: Content-MD5 is %q", s.name, r.Header.Get("Content-MD5"))
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	content := bytes.Repeat([]byte("x"), 5000)
+	upload := func() (*oo.Asset, error) {
+		u := oo.NewUploader(srv.Client())
+		u.SetVerify(true)
+		return u.CreateUploadSource(oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4"), "video", 2048)
+	}
+
+	if _, err := upload(); err != nil {
+		t.Fatal(err)
+	}
+	srv.SetReportMD5(false)
+	asset, err := upload()
+	if !errors.Is(err, oo.ErrCannotVerify) {
+		t.Fatalf("the upload without md5 reported returns %v, want ErrCannotVerify", err)
+	}
+	if asset == nil || !bytes.Equal(srv.Content(asset.EmbedCode), content) {
+		t.Error("the asset isn't returned after the upload which couldn't be verified")
+	}
+}
+
+// countingBody counts the bytes read through the filter
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	return n, err
+}
+
+func TestFilterFuncOnRetries(t *testing.T) {
+	srv := oootest.NewServer("apikey", "secret")
+	defer srv.Close()
+	srv.Fail("PUT", "/oootest/upload/", 503, 1)
+	content := bytes.Repeat([]byte("x"), 3000)
+
+	var calls, read int64
+	u := oo.NewUploader(srv.Client(oo.WithRetryPolicy(oo.RetryPolicy{MaxAttempts: 2, RetryStatus: map[int]bool{503: true}})))
+	u.SetConcurrency(1)
+	u.SetFilterFunc(func(r *http.Request) (*http.Request, error) {
+		atomic.AddInt64(&calls, 1)
+		r.Body = countingBody{r.Body, &read}
+		return r, nil
+	})
+	if _, err := u.CreateUploadSource(oo.NewReaderAtSource(bytes.NewReader(content), int64(len(content)), "video.mp4"), "video", 1000); err != nil {
+		t.Fatal(err)
+	}
+	// 3 chunks and the retry of the failed one
+	if calls != 4 {
+		t.Errorf("the filter is called %v times, want 4", calls)
+	}
+	if read != 4000 {
+		t.Errorf("%v bytes are read through the filter, want 4000", read)
+	}
+}
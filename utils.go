@@ -1,19 +1,45 @@
 package oo
 
 import (
-	"fmt"
-	"io/ioutil"
+	"context"
+	"io"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
+// checkServiceError returns *APIError if the response status differs from expected
 func checkServiceError(r *http.Response, expected int) error {
 	if r.StatusCode != expected {
-		result, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return fmt.Errorf("could't check http error: %v", err)
-		}
 		defer r.Body.Close()
-		return fmt.Errorf("service error: [%v] %v", r.StatusCode, string(result))
+		return newAPIError(r)
 	}
 	return nil
 }
+
+// ctxReader stops reading from the underlying reader once the context is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// jsonFields returns the json keys of the struct fields
+func jsonFields(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		key := strings.Split(tag, ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,60 @@
+package oo
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SignatureCheck is the result of the signature verification
+type SignatureCheck struct {
+	// Canonical is the string the signature is computed from without the secret key
+	Canonical string
+	// Expected is the signature computed for the request, Given is the one sent with it
+	Expected string
+	Given    string
+	// Expires is the time the request stops being valid
+	Expires time.Time
+	Expired bool
+	// Valid is true if the signatures match and the request hasn't expired
+	Valid bool
+}
+
+// VerifySignature computes the signature of the request with the same algorithm as SignRequest
+// and compares it with the signature parameter. The body is read and put back to the request.
+// The error is returned only if the request lacks the signature or expires parameters
+func VerifySignature(r *http.Request, secret string) (*SignatureCheck, error) {
+	q := r.URL.Query()
+	given := q.Get("signature")
+	if given == "" {
+		return nil, fmt.Errorf("signature is absent in the query")
+	}
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read expires: %v", err)
+	}
+	q.Del("signature")
+
+	var body []byte
+	if r.Body != nil {
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, fmt.Errorf("couldn't read body: %v", err)
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	check := &SignatureCheck{
+		Canonical: CanonicalString(r.Method, r.URL.Path, q, body),
+		Given:     given,
+		Expires:   time.Unix(expires, 0),
+	}
+	check.Expected = Signature(secret, check.Canonical)
+	check.Expired = check.Expires.Before(time.Now())
+	check.Valid = subtle.ConstantTimeCompare([]byte(check.Expected), []byte(check.Given)) == 1 && !check.Expired
+	return check, nil
+}